@@ -3,36 +3,238 @@
 package gocore
 
 import (
+	"container/list"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type (
-	// cache defines a type for caching values by key.
-	cache[K comparable, V any, F func(K) (V, error)] struct {
-		sync.RWMutex
-		lookup F
-		values map[K]V
+	// Cache defines a type for caching values by key. Values are retrieved by the lookup
+	// function on first access, and again on access after they expire. If bounded, the
+	// least recently used value is evicted to make room for a new one.
+	//
+	// A Cache is safe for concurrent use. By default, the lookup function is called while
+	// holding the cache's lock, so lookups of all keys are serialized. With WithSingleflight,
+	// the lookup is called without the lock and concurrent lookups of a key share one call.
+	Cache[K comparable, V any] struct {
+		mu     sync.RWMutex
+		lookup func(K) (V, error)
+		ttl    time.Duration
+		max    int
+		values map[K]entry[V]
+		order  *list.List     // keys of a bounded cache, most recently used first
+		calls  map[K]*call[V] // lookups in flight of a singleflight cache
+		stats  struct {
+			hits      atomic.Uint64
+			misses    atomic.Uint64
+			evictions atomic.Uint64
+		}
+	}
+
+	// CacheStats reports the effectiveness of a Cache.
+	CacheStats struct {
+		Hits      uint64
+		Misses    uint64
+		Entries   int
+		Evictions uint64
+	}
+
+	// entry is a cached value and its expiration time.
+	entry[V any] struct {
+		value   V
+		expires time.Time
+		elem    *list.Element
+	}
+
+	// call is a lookup in flight whose result is shared by concurrent callers.
+	call[V any] struct {
+		sync.WaitGroup
+		value V
+		err   error
+	}
+
+	// CacheOption configures a Cache at construction.
+	CacheOption func(*cacheOptions)
+
+	// cacheOptions collects the options of a Cache.
+	cacheOptions struct {
+		maxEntries   int
+		singleflight bool
 	}
 )
 
-// newCache creates a cache for values by key.
-func newCache[K comparable, V any, F func(K) (V, error)](lookup F) *cache[K, V, F] {
-	cache := &cache[K, V, F]{
-		values: map[K]V{},
-	}
-	cache.lookup = func(key K) (V, error) {
-		cache.RLock()
-		value, ok := cache.values[key]
-		cache.RUnlock()
-		var err error
-		if !ok {
-			cache.Lock()
-			value, err = lookup(key)
-			cache.values[key] = value
-			cache.Unlock()
+// WithMaxEntries bounds a cache to n values, evicting the least recently used value when
+// the bound is exceeded. By default, a cache is unbounded.
+func WithMaxEntries(n int) CacheOption {
+	return func(opts *cacheOptions) {
+		opts.maxEntries = n
+	}
+}
+
+// WithSingleflight calls the lookup function without holding the cache's lock, so that a
+// slow lookup of one key does not delay access to others, and shares the result of a lookup
+// with concurrent callers for the same key.
+func WithSingleflight() CacheOption {
+	return func(opts *cacheOptions) {
+		opts.singleflight = true
+	}
+}
+
+// NewCache creates a cache for values by key. Values older than ttl are retrieved anew on
+// next access. A ttl of 0 means values never expire.
+func NewCache[K comparable, V any](lookup func(K) (V, error), ttl time.Duration, options ...CacheOption) *Cache[K, V] {
+	var opts cacheOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	cache := &Cache[K, V]{
+		lookup: lookup,
+		ttl:    ttl,
+		values: map[K]entry[V]{},
+	}
+	if opts.maxEntries > 0 {
+		cache.max = opts.maxEntries
+		cache.order = list.New()
+	}
+	if opts.singleflight {
+		cache.calls = map[K]*call[V]{}
+	}
+	return cache
+}
+
+// Lookup returns the cached value for key, retrieving it with the lookup function if it
+// is not cached or has expired. The value is cached even if the lookup reports an error.
+func (cache *Cache[K, V]) Lookup(key K) (V, error) {
+	if cache.max == 0 { // unbounded cache need not record use of value
+		cache.mu.RLock()
+		e, ok := cache.values[key]
+		cache.mu.RUnlock()
+		if ok && e.fresh() {
+			cache.stats.hits.Add(1)
+			return e.value, nil
 		}
+	}
 
+	cache.mu.Lock()
+	if e, ok := cache.values[key]; ok && e.fresh() { // retrieved while awaiting lock
+		if e.elem != nil {
+			cache.order.MoveToFront(e.elem)
+		}
+		cache.mu.Unlock()
+		cache.stats.hits.Add(1)
+		return e.value, nil
+	}
+	if cache.calls == nil {
+		defer cache.mu.Unlock()
+		cache.stats.misses.Add(1)
+		value, err := cache.lookup(key)
+		cache.store(key, value)
 		return value, err
 	}
-	return cache
+
+	if c, ok := cache.calls[key]; ok { // share the lookup in flight
+		cache.mu.Unlock()
+		c.Wait()
+		cache.stats.hits.Add(1)
+		return c.value, c.err
+	}
+	c := &call[V]{}
+	c.Add(1)
+	cache.calls[key] = c
+	cache.mu.Unlock()
+
+	cache.stats.misses.Add(1)
+	c.value, c.err = cache.lookup(key)
+
+	cache.mu.Lock()
+	cache.store(key, c.value)
+	delete(cache.calls, key)
+	cache.mu.Unlock()
+	c.Done()
+	return c.value, c.err
+}
+
+// Store caches a value for key, replacing any value cached.
+func (cache *Cache[K, V]) Store(key K, value V) {
+	cache.mu.Lock()
+	cache.store(key, value)
+	cache.mu.Unlock()
+}
+
+// Invalidate removes the value cached for key.
+func (cache *Cache[K, V]) Invalidate(key K) {
+	cache.mu.Lock()
+	cache.remove(key)
+	cache.mu.Unlock()
+}
+
+// Clear removes all values cached.
+func (cache *Cache[K, V]) Clear() {
+	cache.mu.Lock()
+	cache.clear()
+	cache.mu.Unlock()
+}
+
+// reset removes all values cached and zeroes the statistics.
+func (cache *Cache[K, V]) reset() {
+	cache.mu.Lock()
+	cache.clear()
+	cache.stats.hits.Store(0)
+	cache.stats.misses.Store(0)
+	cache.stats.evictions.Store(0)
+	cache.mu.Unlock()
+}
+
+// Stats reports the hits, misses, entries, and evictions of the cache.
+func (cache *Cache[K, V]) Stats() CacheStats {
+	cache.mu.RLock()
+	entries := len(cache.values)
+	cache.mu.RUnlock()
+	return CacheStats{
+		Hits:      cache.stats.hits.Load(),
+		Misses:    cache.stats.misses.Load(),
+		Entries:   entries,
+		Evictions: cache.stats.evictions.Load(),
+	}
+}
+
+// store caches a value, evicting least recently used values if bounded. Caller holds the lock.
+func (cache *Cache[K, V]) store(key K, value V) {
+	cache.remove(key)
+	e := entry[V]{value: value}
+	if cache.ttl > 0 {
+		e.expires = time.Now().Add(cache.ttl)
+	}
+	if cache.max > 0 {
+		for len(cache.values) >= cache.max {
+			cache.remove(cache.order.Back().Value.(K))
+			cache.stats.evictions.Add(1)
+		}
+		e.elem = cache.order.PushFront(key)
+	}
+	cache.values[key] = e
+}
+
+// clear removes all values cached. Caller holds the lock.
+func (cache *Cache[K, V]) clear() {
+	cache.values = map[K]entry[V]{}
+	if cache.order != nil {
+		cache.order.Init()
+	}
+}
+
+// remove deletes a cached value. Caller holds the lock.
+func (cache *Cache[K, V]) remove(key K) {
+	if e, ok := cache.values[key]; ok {
+		if e.elem != nil {
+			cache.order.Remove(e.elem)
+		}
+		delete(cache.values, key)
+	}
+}
+
+// fresh reports whether a cached value has not expired.
+func (e entry[V]) fresh() bool {
+	return e.expires.IsZero() || time.Now().Before(e.expires)
 }
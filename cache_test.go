@@ -0,0 +1,142 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	var lookups int
+	cache := NewCache(func(k int) (string, error) {
+		lookups++
+		return strconv.Itoa(k), nil
+	}, 0, WithMaxEntries(2))
+
+	cache.Lookup(1)
+	cache.Lookup(2)
+	cache.Lookup(1) // 2 is now least recently used
+	cache.Lookup(3) // evicts 2
+	cache.Lookup(1)
+	if lookups != 3 {
+		t.Errorf("%d lookups, want 3", lookups)
+	}
+	cache.Lookup(2)
+	if lookups != 4 {
+		t.Errorf("%d lookups after evicted key, want 4", lookups)
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 2 {
+		t.Errorf("stats %+v, want 2 entries and 2 evictions", stats)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	var lookups int
+	cache := NewCache(func(k int) (int, error) {
+		lookups++
+		return k, nil
+	}, 10*time.Millisecond)
+
+	cache.Lookup(1)
+	cache.Lookup(1)
+	if lookups != 1 {
+		t.Errorf("%d lookups before expiry, want 1", lookups)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cache.Lookup(1)
+	if lookups != 2 {
+		t.Errorf("%d lookups after expiry, want 2", lookups)
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	cache := NewCache(func(k int) (int, error) {
+		lookups.Add(1)
+		<-release
+		return k * 2, nil
+	}, 0, WithSingleflight())
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, _ := cache.Lookup(21); v != 42 {
+				t.Errorf("Lookup(21) = %d, want 42", v)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d lookups, want 1", n)
+	}
+}
+
+func BenchmarkCacheHit(b *testing.B) {
+	cache := NewCache(func(k int) (int, error) { return k, nil }, 0)
+	cache.Lookup(1)
+	b.ResetTimer()
+	for range b.N {
+		cache.Lookup(1)
+	}
+}
+
+func BenchmarkCacheHitParallel(b *testing.B) {
+	cache := NewCache(func(k int) (int, error) { return k, nil }, 0)
+	for k := range 1024 {
+		cache.Lookup(k)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var k int
+		for pb.Next() {
+			cache.Lookup(k & 1023)
+			k++
+		}
+	})
+}
+
+func BenchmarkCacheBoundedHit(b *testing.B) {
+	cache := NewCache(func(k int) (int, error) { return k, nil }, 0, WithMaxEntries(1024))
+	for k := range 1024 {
+		cache.Lookup(k)
+	}
+	b.ResetTimer()
+	var k int
+	for range b.N {
+		cache.Lookup(k & 1023)
+		k++
+	}
+}
+
+func BenchmarkCacheBoundedMiss(b *testing.B) {
+	cache := NewCache(func(k int) (int, error) { return k, nil }, 0, WithMaxEntries(1024))
+	var k int
+	for range b.N {
+		cache.Lookup(k) // every key is new, so each lookup evicts
+		k++
+	}
+}
+
+func BenchmarkCacheSingleflightHitParallel(b *testing.B) {
+	cache := NewCache(func(k int) (int, error) { return k, nil }, 0, WithSingleflight())
+	for k := range 1024 {
+		cache.Lookup(k)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var k int
+		for pb.Next() {
+			cache.Lookup(k & 1023)
+			k++
+		}
+	})
+}
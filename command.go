@@ -6,6 +6,7 @@ import "C"
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -13,10 +14,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
+	modver "golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"golang.org/x/sys/unix"
 )
 
@@ -29,6 +34,25 @@ type (
 		Version  string
 		Machine  string
 	}
+
+	// ModuleDep describes a module dependency of the command's build.
+	ModuleDep struct {
+		Path    string
+		Version string
+		Sum     string
+		Replace *ModuleDep // module that replaces this one, if any
+	}
+
+	// VersionInfo describes the build of the command, as reported by the -version flag.
+	VersionInfo struct {
+		Command   string `json:"command"`
+		Module    string `json:"module"`
+		Version   string `json:"version"`
+		BuildDate string `json:"build_date"`
+		Compiler  string `json:"compiler"`
+		OS        string `json:"os"`
+		Arch      string `json:"arch"`
+	}
 )
 
 var (
@@ -61,6 +85,15 @@ var (
 	// Version of module: version.major.minor-timestamp-commithash
 	Version string
 
+	// shutdownGrace is the longest that Main waits for cleanup after its context is cancelled.
+	shutdownGrace = time.Second
+
+	// mainExitCode is the exit code for the error returned by the main function, 0 if none.
+	mainExitCode atomic.Int64
+
+	// warnExitCode is the exit code of a command that reported warnings but no errors.
+	warnExitCode int
+
 	// buildDate sets the build date for the command.
 	buildDate = func() string {
 		info, _ := os.Stat(Executable)
@@ -76,32 +109,114 @@ func Main(main func(context.Context) error) {
 		if !errors.Is(err, flag.ErrHelp) {
 			Error("", err).Err()
 		}
+		exit()
+		return
+	}
+
+	if Flags.version != "" {
+		version(Flags.version)
 		return
 	}
 
-	if Flags.version {
-		version()
+	if Flags.completion != "" {
+		script, err := Flags.Completion(Flags.completion)
+		if err != nil {
+			Error("completion", err).Err()
+			exit()
+		}
+		fmt.Print(script)
 		return
 	}
 
+	replay, record := envName("replay-stdin"), envName("record-stdin")
+	if path := os.Getenv(replay); path != "" {
+		if err := ReplayStdin(path); err != nil {
+			Error(replay, err).Err()
+		} else {
+			Error(replay, nil, map[string]string{"path": path}).Info()
+		}
+	} else if path := os.Getenv(record); path != "" {
+		if err := TeeStdin(path); err != nil {
+			Error(record, err).Err()
+		} else {
+			Error(record, nil, map[string]string{"path": path}).Info()
+		}
+	}
+
 	// ctx, cncl := context.WithCancel(context.Background())
 	ctx, stop := signalContext()
 
 	// set up profiling if requested
 	profile(ctx)
 
+	done := make(chan struct{})
 	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				Error("panic", fmt.Errorf("%v\n%s", r, debug.Stack())).Err()
+				mainExitCode.Store(2) // as the Go runtime does for an unrecovered panic
+			}
+			stop() // on exit, inform service routines to cleanup
+			close(done)
+		}()
+
 		if err := main(ctx); err != nil {
 			Error("exit maini", err).Err()
+			code := 1
+			var coder interface{ ExitCode() int }
+			if errors.As(err, &coder) && coder.ExitCode() > 0 {
+				code = coder.ExitCode() // e.g. of a failed exec.Cmd
+			}
+			mainExitCode.Store(int64(code))
 		}
-		stop() // on exit, inform service routines to cleanup
 	}()
 
 	// run osEnvironment on main thread for the native host application environment setup (e.g. MacOS main run loop)
 	// osEnvironment(ctx)
 
 	<-ctx.Done()
-	<-time.After(time.Millisecond * 1000) // wait a moment for contexts to cleanup and exit
+	close(mainDone)
+
+	cleaned := make(chan struct{})
+	go func() {
+		<-done
+		profiles.pending.Wait()
+		close(cleaned)
+	}()
+	select { // wait for main and profiling to cleanup, for at most the grace period
+	case <-cleaned:
+	case <-time.After(shutdownGrace):
+	}
+	exit()
+}
+
+// SetShutdownGrace sets the longest that Main waits, after its context is cancelled, for the
+// main function to return and profiles to be written, 1 second by default.
+func SetShutdownGrace(d time.Duration) {
+	shutdownGrace = d
+}
+
+// SetWarnExitCode sets the exit code of a command that reported warnings but no errors.
+// By default, such a command exits with 0. A command that reported errors exits with 1, as does
+// a command whose main function returned an error, unless the error reports its own ExitCode.
+func SetWarnExitCode(code int) {
+	warnExitCode = code
+}
+
+// exit terminates the command with the exit code for the main function's error, if it returned
+// one, or otherwise mapped from the highest level of messages reported.
+func exit() {
+	code := int(mainExitCode.Load())
+	switch level := HighestLevel(); {
+	case code != 0:
+	case level >= LevelError:
+		code = 1
+	case level == LevelWarn:
+		code = warnExitCode
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
 }
 
 // build gathers the module and version information for this build.
@@ -113,19 +228,121 @@ func build() (string, string) {
 		// get git repo time and hash
 		cmd := exec.Command("git", "show", "-s", "--format=%cI %H")
 		cmd.Dir = mod.Dir
-		out, _ := cmd.Output()
-		tm, hash, _ := strings.Cut(string(out), " ")
-		t, _ := time.Parse(time.RFC3339, tm)
-		hash = strings.TrimSpace(hash) + strings.Repeat("0", 12)
-		vers = "v0.0.0-" + t.UTC().Format("20060102150405-") + hash[:12]
+		vers = gitVersion(cmd.Output())
 	}
 
 	return mod.Path, vers
 }
 
-// version returns the command's version information.
-func version() {
-	fmt.Fprintf(os.Stderr,
+// gitVersion derives a pseudo-version from the commit time and hash that git show reports.
+func gitVersion(out []byte, err error) string {
+	tm, hash, _ := strings.Cut(string(out), " ")
+	hash = strings.TrimSpace(hash)
+	if t, terr := time.Parse(time.RFC3339, tm); err == nil && terr == nil && len(hash) >= 12 {
+		return "v0.0.0-" + t.UTC().Format("20060102150405-") + hash[:12]
+	}
+	return vcsVersion() // e.g. no git, or repository without commits
+}
+
+// vcsVersion derives a pseudo-version from the version control information that the go command
+// stamps into the build, for a command built where git is not available to build.
+func vcsVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "v0.0.0-unknown"
+	}
+
+	var rev, tm string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			rev = setting.Value
+		case "vcs.time":
+			tm = setting.Value
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, tm); err == nil && len(rev) >= 12 {
+		return "v0.0.0-" + t.UTC().Format("20060102150405-") + rev[:12]
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "v0.0.0-unknown"
+}
+
+// CompareVersions compares two semantic versions, such as the Version that build generates,
+// returning -1, 0, or +1 as a is less than, equal to, or greater than b. The "v" prefix is
+// optional. Two pseudo-versions (vX.Y.Z-timestamp-hash) are ordered by their timestamps. An
+// invalid version is less than any valid version and equal to any other invalid version.
+func CompareVersions(a, b string) int {
+	if !strings.HasPrefix(a, "v") {
+		a = "v" + a
+	}
+	if !strings.HasPrefix(b, "v") {
+		b = "v" + b
+	}
+	if modver.IsPseudoVersion(a) && modver.IsPseudoVersion(b) {
+		ta, erra := modver.PseudoVersionTime(a)
+		tb, errb := modver.PseudoVersionTime(b)
+		if erra == nil && errb == nil {
+			if c := ta.Compare(tb); c != 0 {
+				return c
+			}
+		}
+	}
+	return semver.Compare(a, b)
+}
+
+// Dependencies reports the module dependencies recorded in the command's build information.
+func Dependencies() []ModuleDep {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return []ModuleDep{}
+	}
+	deps := make([]ModuleDep, 0, len(info.Deps))
+	for _, dep := range info.Deps {
+		deps = append(deps, moduleDep(dep))
+	}
+	return deps
+}
+
+// moduleDep converts a build information module to a ModuleDep.
+func moduleDep(mod *debug.Module) ModuleDep {
+	dep := ModuleDep{
+		Path:    mod.Path,
+		Version: mod.Version,
+		Sum:     mod.Sum,
+	}
+	if mod.Replace != nil {
+		replace := moduleDep(mod.Replace)
+		dep.Replace = &replace
+	}
+	return dep
+}
+
+// BuildInfo reports the build of the command, e.g. for a command's own version endpoint.
+// The module and version are determined when Main starts.
+func BuildInfo() VersionInfo {
+	return VersionInfo{
+		Command:   Executable,
+		Module:    module,
+		Version:   Version,
+		BuildDate: buildDate,
+		Compiler:  runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+}
+
+// version reports the command's version information, as text or as JSON.
+func version(format versionFormat) {
+	info := BuildInfo()
+	if format == "json" {
+		b, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Fprintln(console(), string(b))
+		return
+	}
+	fmt.Fprintf(console(),
 		`Command    - %s
 Module     - %s
 Version    - %s
@@ -133,5 +350,5 @@ Build Date - %s
 Compiler   - %s %s_%s
 Copyright © 2021-2023 The Gomon Project.
 `,
-		Executable, module, Version, buildDate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		info.Command, info.Module, info.Version, info.BuildDate, info.Compiler, info.OS, info.Arch)
 }
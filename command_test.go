@@ -0,0 +1,43 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGitVersion(t *testing.T) {
+	out := []byte("2023-05-01T12:34:56-04:00 0123456789abcdef0123456789abcdef01234567\n")
+	if v := gitVersion(out, nil); v != "v0.0.0-20230501163456-0123456789ab" {
+		t.Errorf("gitVersion() = %s", v)
+	}
+
+	fallback := gitVersion(nil, errors.New("no git"))
+	tests := []struct {
+		name string
+		out  string
+		err  error
+	}{
+		{"empty output", "", nil},
+		{"no commits", "", errors.New("fatal: your current branch 'main' does not have any commits yet")},
+		{"no git", "", errors.New(`exec: "git": executable file not found in $PATH`)},
+		{"short hash", "2023-05-01T12:34:56Z 0123\n", nil},
+		{"bad time", "yesterday 0123456789abcdef0123456789abcdef01234567\n", nil},
+	}
+	for _, tt := range tests {
+		v := gitVersion([]byte(tt.out), tt.err)
+		if v != fallback {
+			t.Errorf("%s: gitVersion() = %s, want %s", tt.name, v, fallback)
+		}
+		if CompareVersions(v, "v0.0.0-00010101000000-000000000000") == 0 {
+			t.Errorf("%s: gitVersion() = %s, a zero pseudo-version", tt.name, v)
+		}
+	}
+}
+
+func TestVcsVersion(t *testing.T) {
+	if v := vcsVersion(); v == "" || v[0] != 'v' {
+		t.Errorf("vcsVersion() = %q, want a version", v)
+	}
+}
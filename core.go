@@ -7,17 +7,80 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math"
+	"math/bits"
+	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 	"unsafe"
 )
 
 type (
 	// ValidValue defines list of values that are valid for a type safe string.
 	ValidValue[T ~string] map[T]int
+
+	// Destination selects a standard output stream.
+	Destination int
+
+	// SpawnOption configures the command started by Spawn.
+	SpawnOption func(*exec.Cmd)
+
+	// Session is a command started by SpawnSession that exchanges lines with its caller, e.g.
+	// for a request/response protocol. Its stdout is read concurrently with writes to its stdin
+	// so that the command never blocks on writing a full pipe.
+	Session struct {
+		cmd   *exec.Cmd
+		stdin io.WriteCloser
+		done  chan error
+		once  sync.Once
+		err   error // result of waiting for the command, reported by Close
+		lines struct {
+			sync.Mutex
+			*sync.Cond
+			queue []string
+			eof   bool
+			err   error
+		}
+	}
+
+	// outputPipe reads a command's output from a pipe that, unlike the pipe of cmd.StdoutPipe,
+	// waiting for the command does not close, so that the command is reaped when it exits
+	// without discarding output not yet read. The pipe is closed when it is read to EOF.
+	outputPipe struct {
+		*os.File
+		err error
+	}
+
+	// spool buffers a command's output in memory as it is written, so that the command never
+	// blocks writing it while the caller reads its other output.
+	spool struct {
+		mu   sync.Mutex
+		cond *sync.Cond
+		buf  bytes.Buffer
+		eof  bool
+		err  error
+	}
+
+	// DialOption configures the connection made by DialScanner.
+	DialOption func(*dialOptions)
+
+	// dialOptions collects the options of DialScanner.
+	dialOptions struct {
+		reconnect time.Duration
+	}
 )
 
 const (
@@ -25,6 +88,12 @@ const (
 	RFC3339Milli = "2006-01-02T15:04:05.000Z07:00"
 )
 
+const (
+	// enum of standard output streams.
+	Stderr Destination = iota // default
+	Stdout
+)
+
 var (
 	// HostEndian enables byte order conversion between local and network integers.
 	HostEndian = func() binary.ByteOrder {
@@ -36,8 +105,81 @@ var (
 		}
 		return binary.LittleEndian
 	}()
+
+	// ErrCommandNotFound reports that a command to spawn is not installed or not on the PATH.
+	ErrCommandNotFound = errors.New("command not found")
+
+	// ErrPermissionDenied reports that a command to spawn may not be executed by this user.
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrNotExecutable reports that a command to spawn is not a valid executable for this system.
+	ErrNotExecutable = errors.New("not executable")
+
+	// mainDone is closed when the context of Main is cancelled.
+	mainDone = make(chan struct{})
+
+	// consoleDestination selects the stream for human readable output.
+	consoleDestination = Stderr
+
+	// stopSignals are the signals that cancel the context of Main, if set by SetStopSignals.
+	stopSignals []os.Signal
+
+	// signalHandlers are the functions called on receipt of signals, registered by OnSignal.
+	signalHandlers = map[os.Signal]func(){}
 )
 
+// File returns the standard output stream of the destination.
+func (d Destination) File() *os.File {
+	if d == Stdout {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+// SetConsoleDestination directs human readable output, such as the usage, version, and
+// profiling hints, to standard error (the default) or standard output.
+func SetConsoleDestination(d Destination) {
+	consoleDestination = d
+}
+
+// console returns the stream for human readable output.
+func console() *os.File {
+	return consoleDestination.File()
+}
+
+// SetStopSignals sets the signals that cancel the context of Main, replacing the default of
+// interrupt and terminate. Call it before Main.
+func SetStopSignals(sigs ...os.Signal) {
+	stopSignals = sigs
+}
+
+// OnSignal registers a function to call on each receipt of a signal, e.g. to reload the
+// configuration on SIGHUP, without stopping the command. Call it before Main.
+func OnSignal(sig os.Signal, fn func()) {
+	signalHandlers[sig] = fn
+}
+
+// notifySignals calls the functions registered by OnSignal for their signals until the
+// context is cancelled.
+func notifySignals(ctx context.Context) {
+	if len(signalHandlers) == 0 {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, slices.Collect(maps.Keys(signalHandlers))...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				signalHandlers[sig]()
+			}
+		}
+	}()
+}
+
 // Define initializes a ValidValue type with its valid values.
 func (vv ValidValue[T]) Define(values ...T) ValidValue[T] {
 	vv = map[T]int{}
@@ -67,6 +209,20 @@ func (vv ValidValue[T]) Index(v T) int {
 	return vv[v]
 }
 
+// Parse validates a string and returns its canonical value, matching the valid values exactly
+// or else ignoring case, e.g. to implement the Set method of a flag.
+func (vv ValidValue[T]) Parse(s string) (T, error) {
+	if vv.IsValid(T(s)) {
+		return T(s), nil
+	}
+	for v := range vv {
+		if strings.EqualFold(string(v), s) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("%q is not valid, valid values are %s", s, strings.Join(vv.ValidValues(), ", "))
+}
+
 // ChDir is a convenience function for changing the current directory and reporting its canonical path.
 // If changing the directory fails, ChDir returns the error and canonical path of the current directory.
 func ChDir(dir string) (string, error) {
@@ -94,6 +250,165 @@ func Subdir(base, targ string) (string, error) {
 	}
 }
 
+// SubdirResolved acts like Subdir but first resolves both paths to their canonical form as
+// ChDir does, so that a target reached through a symbolic link is found on the base path.
+func SubdirResolved(base, targ string) (string, error) {
+	var err error
+	for _, path := range []*string{&base, &targ} {
+		if *path, err = filepath.Abs(*path); err != nil {
+			return "", err
+		}
+		if *path, err = filepath.EvalSymlinks(*path); err != nil {
+			return "", err
+		}
+	}
+	return Subdir(base, targ)
+}
+
+// WalkUp searches for a file, such as go.mod, in a start directory and its ancestors, returning
+// the directory that contains it. It reports false if the search reaches the root without a match.
+func WalkUp(start, name string) (string, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir { // root
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// IntToC32 converts an int to an int32, such as a C int, reporting an error if it overflows.
+func IntToC32(n int) (int32, error) {
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return 0, Error("IntToC32", errors.New("integer overflow"), map[string]string{
+			"value": strconv.Itoa(n),
+		})
+	}
+	return int32(n), nil
+}
+
+// IntToCU32 converts an int to a uint32, such as a C unsigned int, reporting an error if it overflows.
+func IntToCU32(n int) (uint32, error) {
+	if n < 0 || uint64(n) > math.MaxUint32 {
+		return 0, Error("IntToCU32", errors.New("integer overflow"), map[string]string{
+			"value": strconv.Itoa(n),
+		})
+	}
+	return uint32(n), nil
+}
+
+// Int64ToInt converts an int64, such as a C long, to an int, reporting an error if it overflows.
+func Int64ToInt(n int64) (int, error) {
+	if n < math.MinInt || n > math.MaxInt {
+		return 0, Error("Int64ToInt", errors.New("integer overflow"), map[string]string{
+			"value": strconv.FormatInt(n, 10),
+		})
+	}
+	return int(n), nil
+}
+
+// Htons converts a uint16 from host to network (big endian) byte order.
+func Htons(v uint16) uint16 {
+	if HostEndian == binary.BigEndian {
+		return v
+	}
+	return bits.ReverseBytes16(v)
+}
+
+// Htonl converts a uint32 from host to network (big endian) byte order.
+func Htonl(v uint32) uint32 {
+	if HostEndian == binary.BigEndian {
+		return v
+	}
+	return bits.ReverseBytes32(v)
+}
+
+// Ntohs converts a uint16 from network (big endian) to host byte order.
+func Ntohs(v uint16) uint16 {
+	return Htons(v)
+}
+
+// Ntohl converts a uint32 from network (big endian) to host byte order.
+func Ntohl(v uint32) uint32 {
+	return Htonl(v)
+}
+
+// ReadUint16 reads a host byte order uint16 from the start of a buffer, e.g. of C data.
+func ReadUint16(b []byte) uint16 {
+	return HostEndian.Uint16(b)
+}
+
+// ReadUint32 reads a host byte order uint32 from the start of a buffer.
+func ReadUint32(b []byte) uint32 {
+	return HostEndian.Uint32(b)
+}
+
+// ReadUint64 reads a host byte order uint64 from the start of a buffer.
+func ReadUint64(b []byte) uint64 {
+	return HostEndian.Uint64(b)
+}
+
+// WriteUint16 writes a uint16 in host byte order to the start of a buffer.
+func WriteUint16(b []byte, v uint16) {
+	HostEndian.PutUint16(b, v)
+}
+
+// WriteUint32 writes a uint32 in host byte order to the start of a buffer.
+func WriteUint32(b []byte, v uint32) {
+	HostEndian.PutUint32(b, v)
+}
+
+// WriteUint64 writes a uint64 in host byte order to the start of a buffer.
+func WriteUint64(b []byte, v uint64) {
+	HostEndian.PutUint64(b, v)
+}
+
+// Decode reads host byte order data from a buffer into v, a pointer to a fixed size value
+// such as a struct of fixed size fields, as binary.Read does.
+func Decode(b []byte, v any) error {
+	if err := binary.Read(bytes.NewReader(b), HostEndian, v); err != nil {
+		return Error("Decode", err)
+	}
+	return nil
+}
+
+// GoString interprets a null terminated C char array as a GO string.
+func GoString[C ~int8 | ~byte](char *C) string {
+	buf := []byte{}
+	for *char != 0 {
+		buf = append(buf, byte(*char))
+		char = (*C)(unsafe.Add(unsafe.Pointer(char), 1))
+	}
+	return string(buf)
+}
+
+// GoStringN interprets a length specified and possibly null terminated C char array as a GO string.
+func GoStringN[
+	C ~int8 | ~byte,
+	L int | uint | int8 | uint8 | int16 | uint16 | int32 | uint32 | int64 | uint64,
+](char *C, l L) string {
+	if l == 0 {
+		return GoString(char)
+	}
+	buf := make([]byte, l)
+	n := len(buf) // no null terminator
+	for i, c := range unsafe.Slice(char, l) {
+		if c == 0 {
+			n = i
+			break
+		}
+		buf[i] = byte(c)
+	}
+	return string(buf[:n])
+}
+
 // IsTerminal reports if a file handle is connected to the terminal.
 func IsTerminal(f *os.File) bool {
 	info, err := f.Stat()
@@ -104,35 +419,482 @@ func IsTerminal(f *os.File) bool {
 	return info.Mode()&terminal == terminal
 }
 
+// ColorEnabled reports whether human readable output may include ANSI color escapes.
+// Following the NO_COLOR (https://no-color.org) and CLICOLOR_FORCE conventions, color is
+// disabled if NO_COLOR is set, forced if CLICOLOR_FORCE is set and not "0", and otherwise
+// enabled if the console output is a terminal.
+func ColorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	return IsTerminal(console())
+}
+
+// TeeStdin records everything read from stdin to a file, for replay with ReplayStdin when
+// reproducing a problem. Main calls TeeStdin if the RECORD_STDIN environment variable, prefixed as
+// set by EnvPrefix, e.g. GOMON_RECORD_STDIN, names a file.
+func TeeStdin(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return Error("TeeStdin", err, map[string]string{
+			"path": path,
+		})
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		f.Close()
+		return Error("TeeStdin", err)
+	}
+
+	stdin := os.Stdin
+	os.Stdin = r
+	go func() {
+		io.Copy(w, io.TeeReader(stdin, f))
+		w.Close()
+		f.Close()
+	}()
+	return nil
+}
+
+// ReplayStdin substitutes a file, such as one recorded by TeeStdin, for stdin. Main calls
+// ReplayStdin if the REPLAY_STDIN environment variable, prefixed as set by EnvPrefix, e.g.
+// GOMON_REPLAY_STDIN, names a file.
+func ReplayStdin(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return Error("ReplayStdin", err, map[string]string{
+			"path": path,
+		})
+	}
+	os.Stdin = f
+	return nil
+}
+
+// WithStdin supplies the command's standard input.
+func WithStdin(stdin io.Reader) SpawnOption {
+	return func(cmd *exec.Cmd) {
+		cmd.Stdin = stdin
+	}
+}
+
+// WithEnv supplies the command's environment in place of the parent's. An empty
+// (non-nil) env runs the command with a scrubbed environment.
+func WithEnv(env []string) SpawnOption {
+	return func(cmd *exec.Cmd) {
+		cmd.Env = env
+	}
+}
+
+// WithDir sets the command's working directory.
+func WithDir(dir string) SpawnOption {
+	return func(cmd *exec.Cmd) {
+		cmd.Dir = dir
+	}
+}
+
+// WithStderr copies the command's standard error to a writer.
+func WithStderr(stderr io.Writer) SpawnOption {
+	return func(cmd *exec.Cmd) {
+		cmd.Stderr = stderr
+	}
+}
+
 // Spawn starts a command and returns a scanner for reading stdout.
-func Spawn(ctx context.Context, cmdline []string) (*bufio.Scanner, error) {
+// If any step fails, resources acquired by earlier steps are released before returning.
+func Spawn(ctx context.Context, cmdline []string, options ...SpawnOption) (*bufio.Scanner, error) {
+	cmd, _, stdout, _, err := start(ctx, cmdline, false, false, options)
+	if err != nil {
+		return nil, err
+	}
+
+	go wait(cmd)
+
+	return bufio.NewScanner(stdout), nil
+}
+
+// SpawnWithStderr starts a command and returns scanners for reading stdout and stderr.
+// Stderr is drained as the command writes it and buffered until read, so the caller may read
+// stdout alone without the command blocking on a full stderr pipe.
+func SpawnWithStderr(ctx context.Context, cmdline []string, options ...SpawnOption) (*bufio.Scanner, *bufio.Scanner, error) {
+	cmd, _, stdout, stderr, err := start(ctx, cmdline, false, true, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sp := &spool{}
+	sp.cond = sync.NewCond(&sp.mu)
+	go sp.fill(stderr)
+	go wait(cmd)
+
+	return bufio.NewScanner(stdout), bufio.NewScanner(sp), nil
+}
+
+// SpawnCmd starts a command and returns it, a channel that delivers the result of waiting
+// for it to complete, and a scanner for reading stdout. A command does not complete while it
+// is blocked writing stdout, so read stdout before receiving from the channel. After receiving
+// from the channel, the command's ProcessState reports its exit status.
+func SpawnCmd(ctx context.Context, cmdline []string, options ...SpawnOption) (*exec.Cmd, <-chan error, *bufio.Scanner, error) {
+	cmd, _, stdout, _, err := start(ctx, cmdline, false, false, options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- wait(cmd)
+		close(done)
+	}()
+
+	return cmd, done, bufio.NewScanner(stdout), nil
+}
+
+// Read reads from the pipe, closing it when the read fails, e.g. at EOF. If the reader
+// abandons the pipe before EOF, the pipe is closed when it is garbage collected, so that a
+// command blocked writing to it fails with EPIPE or SIGPIPE rather than blocking forever.
+func (p *outputPipe) Read(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.File.Read(b)
+	if err != nil {
+		p.err = err
+		p.File.Close()
+	}
+	return n, err
+}
+
+// fill copies a reader into the spool until the reader fails, e.g. at EOF.
+func (sp *spool) fill(r io.Reader) {
+	b := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(b)
+		sp.mu.Lock()
+		sp.buf.Write(b[:n])
+		if err != nil {
+			sp.eof = true
+			if err != io.EOF {
+				sp.err = err
+			}
+		}
+		sp.cond.Broadcast()
+		sp.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read reads the spooled output, waiting for more if the spool is empty but not at EOF.
+func (sp *spool) Read(b []byte) (int, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for sp.buf.Len() == 0 && !sp.eof {
+		sp.cond.Wait()
+	}
+	if sp.buf.Len() > 0 {
+		return sp.buf.Read(b)
+	}
+	if sp.err != nil {
+		return 0, sp.err
+	}
+	return 0, io.EOF
+}
+
+// SpawnSession starts a command and returns a session for writing to its stdin and reading
+// lines from its stdout.
+func SpawnSession(ctx context.Context, cmdline []string, options ...SpawnOption) (*Session, error) {
+	cmd, stdin, stdout, _, err := start(ctx, cmdline, true, false, options)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		cmd:   cmd,
+		stdin: stdin,
+		done:  make(chan error, 1),
+	}
+	s.lines.Cond = sync.NewCond(&s.lines.Mutex)
+
+	go func() {
+		sc := bufio.NewScanner(stdout)
+		for sc.Scan() {
+			s.lines.Lock()
+			s.lines.queue = append(s.lines.queue, sc.Text())
+			s.lines.Signal()
+			s.lines.Unlock()
+		}
+		s.lines.Lock()
+		s.lines.eof = true
+		s.lines.err = sc.Err()
+		s.lines.Broadcast()
+		s.lines.Unlock()
+		io.Copy(io.Discard, stdout) // e.g. after a line too long, so that the command may exit
+		s.done <- wait(cmd)
+	}()
+
+	return s, nil
+}
+
+// Cmd returns the session's command.
+func (s *Session) Cmd() *exec.Cmd {
+	return s.cmd
+}
+
+// Write writes to the command's stdin.
+func (s *Session) Write(b []byte) (int, error) {
+	return s.stdin.Write(b)
+}
+
+// ReadLine returns the next line of the command's stdout, waiting for it if necessary.
+// After the command closes its stdout, ReadLine returns io.EOF.
+func (s *Session) ReadLine() (string, error) {
+	s.lines.Lock()
+	defer s.lines.Unlock()
+	for len(s.lines.queue) == 0 && !s.lines.eof {
+		s.lines.Wait()
+	}
+	if len(s.lines.queue) > 0 {
+		line := s.lines.queue[0]
+		s.lines.queue = s.lines.queue[1:]
+		return line, nil
+	}
+	if s.lines.err != nil {
+		return "", s.lines.err
+	}
+	return "", io.EOF
+}
+
+// Close closes the command's stdin and waits for the command to complete, returning its exit error.
+// Lines of stdout not yet read remain available to ReadLine.
+func (s *Session) Close() error {
+	s.once.Do(func() {
+		s.stdin.Close()
+		s.err = <-s.done
+	})
+	return s.err
+}
+
+// start starts a command with pipes for writing its stdin, if requested, reading its stdout
+// and, if requested, reading its stderr.
+// If any step fails, resources acquired by earlier steps are released before returning.
+func start(ctx context.Context, cmdline []string, withStdin, withStderr bool, options []SpawnOption) (*exec.Cmd, io.WriteCloser, io.ReadCloser, io.ReadCloser, error) {
+	if len(cmdline) == 0 {
+		return nil, nil, nil, nil, Error("Spawn", errors.New("empty command line"))
+	}
 	cmd := exec.CommandContext(ctx, cmdline[0], cmdline[1:]...)
+	for _, option := range options {
+		option(cmd)
+	}
 
 	cmd.ExtraFiles = extraFiles()
-	stdout, err := cmd.StdoutPipe()
+	var stdin io.WriteCloser
+	var stdout, stderr io.ReadCloser
+	var pipes []io.Closer  // the parent's ends of the pipes
+	var writers []*os.File // the child's ends of the output pipes, for the parent to close
+	release := func() {
+		for _, pipe := range pipes {
+			pipe.Close()
+		}
+		for _, w := range writers {
+			w.Close()
+		}
+	}
+	// the command holds the child's ends of the pipes it creates until it starts, so check
+	// that no pipe fails for a stream already set by an option before creating any
+	var err error
+	switch {
+	case withStdin && cmd.Stdin != nil:
+		err = errors.New("stdin already set")
+	case cmd.Stdout != nil:
+		err = errors.New("stdout already set")
+	case withStderr && cmd.Stderr != nil:
+		err = errors.New("stderr already set")
+	}
 	if err != nil {
-		return nil, Error("StdoutPipe", err, map[string]string{
+		return nil, nil, nil, nil, Error("Spawn", err, map[string]string{
 			"command": cmd.String(),
 		})
 	}
+	if withStdin {
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return nil, nil, nil, nil, Error("StdinPipe", err, map[string]string{
+				"command": cmd.String(),
+			})
+		}
+		pipes = append(pipes, stdin)
+	}
+	output := func() (io.ReadCloser, *os.File, error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		pipes = append(pipes, r)
+		writers = append(writers, w)
+		return &outputPipe{File: r}, w, nil
+	}
+	if stdout, cmd.Stdout, err = output(); err != nil {
+		release()
+		return nil, nil, nil, nil, Error("StdoutPipe", err, map[string]string{
+			"command": cmd.String(),
+		})
+	}
+	if withStderr {
+		if stderr, cmd.Stderr, err = output(); err != nil {
+			release()
+			return nil, nil, nil, nil, Error("StderrPipe", err, map[string]string{
+				"command": cmd.String(),
+			})
+		}
+	}
 	if err := cmd.Start(); err != nil {
-		return nil, Error("Start", err, map[string]string{
+		release()
+		return nil, nil, nil, nil, Error("Start", startError(err), map[string]string{
 			"command": cmd.String(),
 		})
 	}
+	for _, w := range writers { // the child has its own copies
+		w.Close()
+	}
 
 	Error("spawn", nil, map[string]string{
 		"command": cmd.String(),
 		"pid":     strconv.Itoa(cmd.Process.Pid),
 	}).Info()
 
-	go wait(cmd)
+	return cmd, stdin, stdout, stderr, nil
+}
 
-	return bufio.NewScanner(stdout), nil
+// startError classifies the cause of a command failing to start, so that callers may test
+// for it with errors.Is, e.g. errors.Is(err, ErrCommandNotFound).
+func startError(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "chdir" { // working directory, not command, missing
+		return err
+	}
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w: %w", ErrCommandNotFound, err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%w: %w", ErrPermissionDenied, err)
+	case errors.Is(err, syscall.ENOEXEC):
+		return fmt.Errorf("%w: %w", ErrNotExecutable, err)
+	}
+	return err
+}
+
+// WithReconnect redials a connection that closes, after a delay, until the context is cancelled.
+func WithReconnect(delay time.Duration) DialOption {
+	return func(opts *dialOptions) {
+		opts.reconnect = delay
+	}
+}
+
+// DialScanner connects to an address, such as a local daemon's Unix socket, and returns a
+// scanner for reading the stream, closing the connection when the context is cancelled.
+func DialScanner(ctx context.Context, network, address string, options ...DialOption) (*bufio.Scanner, error) {
+	var opts dialOptions
+	for _, option := range options {
+		option(&opts)
+	}
+
+	conn, err := dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.reconnect <= 0 {
+		context.AfterFunc(ctx, func() { conn.Close() })
+		return bufio.NewScanner(conn), nil
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		defer w.Close()
+		for {
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			_, err := io.Copy(w, conn)
+			stop()
+			conn.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			Error("connection closed", err, map[string]string{
+				"network": network,
+				"address": address,
+			}).Warn()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(opts.reconnect):
+				}
+				if conn, err = dial(ctx, network, address); err == nil {
+					break
+				}
+			}
+		}
+	}()
+
+	return bufio.NewScanner(r), nil
+}
+
+// dial connects to an address.
+func dial(ctx context.Context, network, address string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, Error("Dial", err, map[string]string{
+			"network": network,
+			"address": address,
+		})
+	}
+
+	Error("dial", nil, map[string]string{
+		"network": network,
+		"address": address,
+	}).Info()
+
+	return conn, nil
+}
+
+// Retry calls a function until it succeeds, for at most a number of attempts, waiting between
+// attempts for a backoff that doubles after each failure. It returns early if the context is
+// cancelled. The error returned after the final attempt wraps the error of the last failure.
+func Retry[T any](ctx context.Context, attempts int, backoff time.Duration, fn func() (T, error)) (T, error) {
+	var zero T
+	for attempt := 1; ; attempt++ {
+		v, err := fn()
+		if err == nil {
+			return v, nil
+		}
+		if attempt >= attempts {
+			return zero, Error("Retry", err, map[string]string{
+				"attempts": strconv.Itoa(attempt),
+			})
+		}
+
+		Error("Retry", err, map[string]string{
+			"attempt": strconv.Itoa(attempt),
+			"backoff": backoff.String(),
+		}).Debug()
+
+		select {
+		case <-ctx.Done():
+			return zero, Error("Retry", fmt.Errorf("%w: %w", ctx.Err(), err), map[string]string{
+				"attempts": strconv.Itoa(attempt),
+			})
+		case <-time.After(Jitter(backoff, 0.1)):
+		}
+		backoff *= 2
+	}
 }
 
 // wait for a started command to complete and report its exit status.
-func wait(cmd *exec.Cmd) {
+func wait(cmd *exec.Cmd) error {
 	err := cmd.Wait()
 	state := cmd.ProcessState
 	var stderr string
@@ -142,11 +904,13 @@ func wait(cmd *exec.Cmd) {
 		}
 	}
 	Error("wait", err, map[string]string{
-		"command":  cmd.Args[0],
-		"pid":      strconv.Itoa(cmd.Process.Pid),
-		"rc":       strconv.Itoa(state.ExitCode()),
-		"systime":  state.SystemTime().String(),
-		"usertime": state.UserTime().String(),
-		"stderr":   stderr,
-	}).Info()
+		"command": cmd.Args[0],
+		"pid":     strconv.Itoa(cmd.Process.Pid),
+		"rc":      strconv.Itoa(state.ExitCode()),
+		"stderr":  stderr,
+	}).
+		WithDuration("systime", state.SystemTime()).
+		WithDuration("usertime", state.UserTime()).
+		Info()
+	return err
 }
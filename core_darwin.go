@@ -7,6 +7,7 @@ package gocore
 #cgo LDFLAGS: -framework CoreFoundation
 #import <CoreFoundation/CoreFoundation.h>
 #include <libproc.h>
+#include <mach/mach.h>
 #include <sys/sysctl.h>
 
 // createCFString required to avoid go vet warning "possible misuse of unsafe.Pointer".
@@ -19,7 +20,9 @@ import "C"
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
 	"syscall"
 	"time"
 	"unsafe"
@@ -43,33 +46,35 @@ type (
 	CFDictionaryRef = C.CFDictionaryRef
 )
 
-var (
-	// Boottime retrieves the system boot time.
-	Boottime = func() time.Time {
-		var timespec C.struct_timespec
-		size := C.size_t(C.sizeof_struct_timespec)
-		if rv, err := C.sysctl(
-			&[]C.int{C.CTL_KERN, C.KERN_BOOTTIME}[0],
-			2,
-			unsafe.Pointer(&timespec),
-			&size,
-			unsafe.Pointer(nil),
-			0,
-		); rv != 0 {
-			Error("sysctl kern.boottime", err).Err()
-			return time.Time{}
-		}
+// boottime retrieves the system boot time.
+func boottime() time.Time {
+	var timespec C.struct_timespec
+	size := C.size_t(C.sizeof_struct_timespec)
+	if rv, err := C.sysctl(
+		&[]C.int{C.CTL_KERN, C.KERN_BOOTTIME}[0],
+		2,
+		unsafe.Pointer(&timespec),
+		&size,
+		unsafe.Pointer(nil),
+		0,
+	); rv != 0 {
+		Error("sysctl kern.boottime", err).Err()
+		return time.Time{}
+	}
 
-		return time.Unix(int64(timespec.tv_sec), int64(timespec.tv_nsec))
-	}()
-)
+	return time.Unix(int64(timespec.tv_sec), int64(timespec.tv_nsec))
+}
 
 // FdPath gets the path for an open file descriptor.
 func FdPath(fd int) (string, error) {
+	cfd, err := IntToC32(fd)
+	if err != nil {
+		return "", err
+	}
 	var fdi C.struct_vnode_fdinfowithpath
 	if n, err := C.proc_pidfdinfo(
 		C.int(os.Getpid()),
-		C.int(fd),
+		C.int(cfd),
 		C.PROC_PIDFDVNODEPATHINFO,
 		unsafe.Pointer(&fdi),
 		C.PROC_PIDFDVNODEPATHINFO_SIZE,
@@ -79,6 +84,128 @@ func FdPath(fd int) (string, error) {
 	return C.GoString(&fdi.pvip.vip_path[0]), nil
 }
 
+// Processes lists the process ids of all processes on the system.
+func Processes() ([]int, error) {
+	n, err := C.proc_listpids(C.PROC_ALL_PIDS, 0, nil, 0)
+	if n <= 0 {
+		return nil, Error("proc_listpids PROC_ALL_PIDS", err)
+	}
+	size := C.int(unsafe.Sizeof(C.int(0)))
+	cpids := make([]C.int, n/size+16) // room for processes started since sizing
+	if n, err = C.proc_listpids(
+		C.PROC_ALL_PIDS,
+		0,
+		unsafe.Pointer(&cpids[0]),
+		C.int(len(cpids))*size,
+	); n <= 0 {
+		return nil, Error("proc_listpids PROC_ALL_PIDS", err)
+	}
+	pids := make([]int, 0, n/size)
+	for _, pid := range cpids[:n/size] {
+		if pid > 0 {
+			pids = append(pids, int(pid))
+		}
+	}
+	return pids, nil
+}
+
+// OpenFDCount counts the open file descriptors of this process.
+func OpenFDCount() (int, error) {
+	n, err := C.proc_pidinfo(
+		C.int(os.Getpid()),
+		C.PROC_PIDLISTFDS,
+		0,
+		nil,
+		0,
+	)
+	if n <= 0 {
+		return 0, Error("proc_pidinfo PROC_PIDLISTFDS", err)
+	}
+	return int(n / C.PROC_PIDLISTFD_SIZE), nil
+}
+
+// ProcessSockets reports the internet sockets held open by a process.
+func ProcessSockets(pid int) ([]SocketInfo, error) {
+	cpid, err := IntToC32(pid)
+	if err != nil {
+		return nil, err
+	}
+	n, err := C.proc_pidinfo(C.int(cpid), C.PROC_PIDLISTFDS, 0, nil, 0)
+	if n <= 0 {
+		return nil, Error("proc_pidinfo PROC_PIDLISTFDS", err)
+	}
+	fds := make([]C.struct_proc_fdinfo, n/C.PROC_PIDLISTFD_SIZE)
+	if n, err = C.proc_pidinfo(
+		C.int(cpid),
+		C.PROC_PIDLISTFDS,
+		0,
+		unsafe.Pointer(&fds[0]),
+		n,
+	); n <= 0 {
+		return nil, Error("proc_pidinfo PROC_PIDLISTFDS", err)
+	}
+	fds = fds[:n/C.PROC_PIDLISTFD_SIZE]
+
+	var sockets []SocketInfo
+	for _, fd := range fds {
+		if fd.proc_fdtype != C.PROX_FDTYPE_SOCKET {
+			continue
+		}
+		var si C.struct_socket_fdinfo
+		if n, _ := C.proc_pidfdinfo(
+			C.int(cpid),
+			C.int(fd.proc_fd),
+			C.PROC_PIDFDSOCKETINFO,
+			unsafe.Pointer(&si),
+			C.PROC_PIDFDSOCKETINFO_SIZE,
+		); n <= 0 {
+			continue // closed since listed
+		}
+		if socket, ok := socketInfo(&si.psi); ok {
+			sockets = append(sockets, socket)
+		}
+	}
+	return sockets, nil
+}
+
+// socketInfo converts the socket information of an internet socket.
+func socketInfo(psi *C.struct_socket_info) (SocketInfo, bool) {
+	var ini *C.struct_in_sockinfo
+	var protocol, state string
+	switch psi.soi_kind {
+	case C.SOCKINFO_TCP:
+		tcp := (*C.struct_tcp_sockinfo)(unsafe.Pointer(&psi.soi_proto[0]))
+		ini = &tcp.tcpsi_ini
+		protocol, state = "tcp", tcpStates[tcp.tcpsi_state]
+	case C.SOCKINFO_IN:
+		ini = (*C.struct_in_sockinfo)(unsafe.Pointer(&psi.soi_proto[0]))
+		protocol = "udp"
+	default:
+		return SocketInfo{}, false
+	}
+
+	var local, remote netip.Addr
+	if ini.insi_vflag&C.INI_IPV6 != 0 {
+		protocol += "6"
+		local = netip.AddrFrom16(*(*[16]byte)(unsafe.Pointer(&ini.insi_laddr[0])))
+		remote = netip.AddrFrom16(*(*[16]byte)(unsafe.Pointer(&ini.insi_faddr[0])))
+	} else {
+		local = netip.AddrFrom4(*(*[4]byte)(unsafe.Pointer(&ini.insi_laddr[12]))) // in4in6_addr
+		remote = netip.AddrFrom4(*(*[4]byte)(unsafe.Pointer(&ini.insi_faddr[12])))
+	}
+	return SocketInfo{
+		Protocol: protocol,
+		Local:    netip.AddrPortFrom(local, netPort(ini.insi_lport)),
+		Remote:   netip.AddrPortFrom(remote, netPort(ini.insi_fport)),
+		State:    state,
+	}, true
+}
+
+// netPort converts a port in network byte order to host byte order.
+func netPort(port C.int) uint16 {
+	return Ntohs(uint16(port))
+}
+
 // MountMap builds a map of mount points to file systems.
 func MountMap() (map[string]string, error) {
 	n, err := syscall.Getfsstat(nil, C.MNT_NOWAIT)
@@ -95,12 +222,99 @@ func MountMap() (map[string]string, error) {
 		if f.Blocks == 0 {
 			continue
 		}
-		m[C.GoString((*C.char)(&f.Mntonname[0]))] =
-			C.GoString((*C.char)(&f.Mntfromname[0]))
+		m[GoStringN(&f.Mntonname[0], len(f.Mntonname))] =
+			GoStringN(&f.Mntfromname[0], len(f.Mntfromname))
 	}
 	return m, nil
 }
 
+// Measures reads a set of system counters and produces a map of name:value pairs. The sets
+// supported are vm.loadavg, vm.swapusage, vm.stats (virtual memory page counts), and
+// kern.cp_time (CPU ticks by state).
+func Measures(name string) (map[string]string, error) {
+	switch name {
+	case "vm.loadavg":
+		var load C.struct_loadavg
+		if err := sysctlbyname(name, unsafe.Pointer(&load), C.sizeof_struct_loadavg); err != nil {
+			return nil, err
+		}
+		scale := float64(load.fscale)
+		return map[string]string{
+			"load1":  strconv.FormatFloat(float64(load.ldavg[0])/scale, 'f', 2, 64),
+			"load5":  strconv.FormatFloat(float64(load.ldavg[1])/scale, 'f', 2, 64),
+			"load15": strconv.FormatFloat(float64(load.ldavg[2])/scale, 'f', 2, 64),
+		}, nil
+	case "vm.swapusage":
+		var swap C.struct_xsw_usage
+		if err := sysctlbyname(name, unsafe.Pointer(&swap), C.sizeof_struct_xsw_usage); err != nil {
+			return nil, err
+		}
+		return map[string]string{
+			"total": strconv.FormatUint(uint64(swap.xsu_total), 10),
+			"avail": strconv.FormatUint(uint64(swap.xsu_avail), 10),
+			"used":  strconv.FormatUint(uint64(swap.xsu_used), 10),
+		}, nil
+	case "vm.stats":
+		var vm C.vm_statistics64_data_t
+		count := C.mach_msg_type_number_t(unsafe.Sizeof(vm) / unsafe.Sizeof(C.integer_t(0)))
+		if kr := C.host_statistics64(
+			C.mach_host_self(),
+			C.HOST_VM_INFO64,
+			C.host_info64_t(unsafe.Pointer(&vm)),
+			&count,
+		); kr != C.KERN_SUCCESS {
+			return nil, Error("host_statistics64 HOST_VM_INFO64", fmt.Errorf("kern_return_t %d", kr))
+		}
+		return map[string]string{
+			"page_size":             strconv.Itoa(os.Getpagesize()),
+			"free_count":            strconv.FormatUint(uint64(vm.free_count), 10),
+			"active_count":          strconv.FormatUint(uint64(vm.active_count), 10),
+			"inactive_count":        strconv.FormatUint(uint64(vm.inactive_count), 10),
+			"wire_count":            strconv.FormatUint(uint64(vm.wire_count), 10),
+			"speculative_count":     strconv.FormatUint(uint64(vm.speculative_count), 10),
+			"compressor_page_count": strconv.FormatUint(uint64(vm.compressor_page_count), 10),
+			"pageins":               strconv.FormatUint(uint64(vm.pageins), 10),
+			"pageouts":              strconv.FormatUint(uint64(vm.pageouts), 10),
+			"swapins":               strconv.FormatUint(uint64(vm.swapins), 10),
+			"swapouts":              strconv.FormatUint(uint64(vm.swapouts), 10),
+		}, nil
+	case "kern.cp_time":
+		var cpu C.host_cpu_load_info_data_t
+		count := C.mach_msg_type_number_t(unsafe.Sizeof(cpu) / unsafe.Sizeof(C.integer_t(0)))
+		if kr := C.host_statistics(
+			C.mach_host_self(),
+			C.HOST_CPU_LOAD_INFO,
+			C.host_info_t(unsafe.Pointer(&cpu)),
+			&count,
+		); kr != C.KERN_SUCCESS {
+			return nil, Error("host_statistics HOST_CPU_LOAD_INFO", fmt.Errorf("kern_return_t %d", kr))
+		}
+		return map[string]string{
+			"user":   strconv.FormatUint(uint64(cpu.cpu_ticks[C.CPU_STATE_USER]), 10),
+			"system": strconv.FormatUint(uint64(cpu.cpu_ticks[C.CPU_STATE_SYSTEM]), 10),
+			"idle":   strconv.FormatUint(uint64(cpu.cpu_ticks[C.CPU_STATE_IDLE]), 10),
+			"nice":   strconv.FormatUint(uint64(cpu.cpu_ticks[C.CPU_STATE_NICE]), 10),
+		}, nil
+	}
+
+	return nil, Unsupported()
+}
+
+// sysctlbyname reads the value of a named sysctl into a buffer.
+func sysctlbyname(name string, p unsafe.Pointer, size C.size_t) error {
+	cs := C.CString(name)
+	defer C.free(unsafe.Pointer(cs))
+	if rv, err := C.sysctlbyname(cs, p, &size, nil, 0); rv != 0 {
+		return Error("sysctlbyname "+name, err)
+	}
+	return nil
+}
+
+// ContainerInfo reports whether the command runs in a container, which is not supported on Darwin.
+func ContainerInfo() (bool, string, error) {
+	return false, "", Unsupported()
+}
+
 // CreateCFString copies a Go string as a Core Foundation CFString. Requires CFRelease be called when done.
 func CreateCFString(s string) unsafe.Pointer {
 	cs := C.CString(s)
@@ -207,6 +421,23 @@ func GetCFValue(v unsafe.Pointer) any {
 	}
 }
 
+var (
+	// tcpStates maps the TCP states of socket information to their names.
+	tcpStates = map[C.int]string{
+		C.TSI_S_CLOSED:       "CLOSED",
+		C.TSI_S_LISTEN:       "LISTEN",
+		C.TSI_S_SYN_SENT:     "SYN_SENT",
+		C.TSI_S_SYN_RECEIVED: "SYN_RECV",
+		C.TSI_S_ESTABLISHED:  "ESTABLISHED",
+		C.TSI_S__CLOSE_WAIT:  "CLOSE_WAIT",
+		C.TSI_S_FIN_WAIT_1:   "FIN_WAIT1",
+		C.TSI_S_CLOSING:      "CLOSING",
+		C.TSI_S_LAST_ACK:     "LAST_ACK",
+		C.TSI_S_FIN_WAIT_2:   "FIN_WAIT2",
+		C.TSI_S_TIME_WAIT:    "TIME_WAIT",
+	}
+)
+
 // darkmode is called from viewDidChangeEffectiveAppearance to report changes to the system appearance.
 // It must be defined separately from the declaration in core_darwin.go to prevent duplicate symbol link error.
 // From the CGO documentation (https://golang.google.cn/cmd/cgo#hdr-C_references_to_Go):
@@ -220,6 +451,7 @@ func GetCFValue(v unsafe.Pointer) any {
 //export darkmode
 func darkmode(dark C.bool) {
 	DarkAppearance = bool(dark)
+	AppearanceEvents.TryPublish(DarkAppearance)
 }
 
 // extraFiles called by Spawn to nil fds beyond 2 (stderr) so that they are closed on exec.
@@ -4,72 +4,94 @@ package gocore
 
 import (
 	"bufio"
+	"errors"
+	"io"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
-	"unsafe"
 )
 
 var (
-	// Boottime gets the system boot time.
-	Boottime = func() time.Time {
-		f, err := os.Open("/proc/stat")
-		if err != nil {
-			Error("/proc/stat open", err).Err()
+	// tcpStates maps the states of the socket tables to their names.
+	tcpStates = map[uint64]string{
+		0x01: "ESTABLISHED",
+		0x02: "SYN_SENT",
+		0x03: "SYN_RECV",
+		0x04: "FIN_WAIT1",
+		0x05: "FIN_WAIT2",
+		0x06: "TIME_WAIT",
+		0x07: "CLOSE",
+		0x08: "CLOSE_WAIT",
+		0x09: "LAST_ACK",
+		0x0A: "LISTEN",
+		0x0B: "CLOSING",
+	}
+)
+
+// boottime gets the system boot time.
+func boottime() time.Time {
+	t, err := btime()
+	if err != nil {
+		Error("Boottime", err).Warn() // fall back to /proc/uptime
+		if t, err = uptime(); err != nil {
+			Error("Boottime", err).Err()
 			return time.Time{}
 		}
-		defer f.Close()
-		sc := bufio.NewScanner(f)
-		for sc.Scan() {
-			l := sc.Text()
-			k, v, _ := strings.Cut(l, " ")
-			switch k {
-			case "btime":
-				sec, err := strconv.Atoi(v)
-				if err != nil {
-					Error("/proc/stat btime", err).Err()
-					return time.Time{}
-				}
-				return time.Unix(int64(sec), 0)
+	}
+	return t
+}
+
+// btime reads the system boot time from /proc/stat.
+func btime() (time.Time, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, Error("/proc/stat open", err)
+	}
+	defer f.Close()
+	return statBtime(f)
+}
+
+// statBtime scans the content of /proc/stat for the btime line.
+func statBtime(r io.Reader) (time.Time, error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if k, v, _ := strings.Cut(sc.Text(), " "); k == "btime" {
+			sec, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return time.Time{}, Error("/proc/stat btime", err)
 			}
+			return time.Unix(int64(sec), 0), nil
 		}
+	}
+	if err := sc.Err(); err != nil {
+		return time.Time{}, Error("/proc/stat read", err)
+	}
+	return time.Time{}, Error("/proc/stat btime", errors.New("btime not found"))
+}
 
-		Error("/proc/stat btime", sc.Err()).Err()
-		return time.Time{}
-	}()
-)
-
-// GoStringN interprets a null terminated C char array as a GO string.
-func GoString[C int8 | byte](char *C) string {
-	buf := []byte{}
-	for *char != 0 {
-		buf = append(buf, byte(*char))
-		char = (*C)(unsafe.Add(unsafe.Pointer(char), 1))
+// uptime derives the system boot time from the uptime reported in /proc/uptime.
+func uptime() (time.Time, error) {
+	b, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return time.Time{}, Error("/proc/uptime read", err)
 	}
-	return string(buf)
+	return uptimeBoot(string(b), time.Now())
 }
 
-// GoStringN interprets a length specified and possibly null terminated C char array as a GO string.
-func GoStringN[
-	C int8 | byte,
-	L int | uint | int8 | uint8 | int16 | uint16 | int32 | uint32 | int64 | uint64,
-](char *C, l L) string {
-	if l == 0 {
-		return GoString(char)
+// uptimeBoot derives the boot time from the content of /proc/uptime read at a time.
+func uptimeBoot(s string, now time.Time) (time.Time, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return time.Time{}, Error("/proc/uptime", errors.New("uptime not found"))
 	}
-	buf := make([]byte, l)
-	cs := unsafe.Slice(char, l)
-	var c C
-	var i int
-	for i, c = range cs {
-		if c == 0 {
-			break
-		}
-		buf[i] = byte(c)
+	sec, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return time.Time{}, Error("/proc/uptime", err)
 	}
-	return string(buf[:i])
+	return now.Add(-time.Duration(sec * float64(time.Second))).Truncate(time.Second), nil
 }
 
 // FdPath gets the path for an open file descriptor.
@@ -77,6 +99,139 @@ func FdPath(fd int) (string, error) {
 	return os.Readlink(filepath.Join("/proc", "self", "fd", strconv.Itoa(fd)))
 }
 
+// Processes lists the process ids of all processes on the system.
+func Processes() ([]int, error) {
+	dir, err := os.Open("/proc")
+	if err != nil {
+		return nil, Error("/proc open", err)
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(0)
+	if err != nil {
+		return nil, Error("/proc read", err)
+	}
+	pids := make([]int, 0, len(names))
+	for _, name := range names {
+		if pid, err := strconv.Atoi(name); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// OpenFDCount counts the open file descriptors of this process.
+func OpenFDCount() (int, error) {
+	dir, err := os.Open(filepath.Join("/proc", "self", "fd"))
+	if err != nil {
+		return 0, Error("/proc/self/fd open", err)
+	}
+	defer dir.Close()
+	fds, err := dir.Readdirnames(0)
+	if err != nil {
+		return 0, Error("/proc/self/fd read", err)
+	}
+	return len(fds) - 1, nil // exclude descriptor reading the directory
+}
+
+// ProcessSockets reports the internet sockets held open by a process, matching the socket
+// inodes of its file descriptors to the entries of its network namespace's socket tables.
+func ProcessSockets(pid int) ([]SocketInfo, error) {
+	dirname := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+	dir, err := os.Open(dirname)
+	if err != nil {
+		return nil, Error(dirname+" open", err)
+	}
+	fds, err := dir.Readdirnames(0)
+	dir.Close()
+	if err != nil {
+		return nil, Error(dirname+" read", err)
+	}
+	inodes := map[string]struct{}{}
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(dirname, fd))
+		if err != nil {
+			continue
+		}
+		if inode, ok := strings.CutPrefix(link, "socket:["); ok {
+			inodes[strings.TrimSuffix(inode, "]")] = struct{}{}
+		}
+	}
+
+	var sockets []SocketInfo
+	for _, protocol := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		filename := filepath.Join("/proc", strconv.Itoa(pid), "net", protocol)
+		f, err := os.Open(filename)
+		if err != nil {
+			continue // e.g. IPv6 disabled
+		}
+		sockets = append(sockets, socketTable(f, protocol, inodes)...)
+		f.Close()
+	}
+	return sockets, nil
+}
+
+// socketTable reads the entries of a /proc/<pid>/net socket table for the socket inodes.
+func socketTable(r io.Reader, protocol string, inodes map[string]struct{}) []SocketInfo {
+	var sockets []SocketInfo
+	sc := bufio.NewScanner(r)
+	sc.Scan() // skip header
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		if _, ok := inodes[fields[9]]; !ok {
+			continue
+		}
+		local, err := socketAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		remote, err := socketAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		var state string
+		if strings.HasPrefix(protocol, "tcp") {
+			st, _ := strconv.ParseUint(fields[3], 16, 8)
+			state = tcpStates[st]
+		}
+		sockets = append(sockets, SocketInfo{
+			Protocol: protocol,
+			Local:    local,
+			Remote:   remote,
+			State:    state,
+		})
+	}
+	return sockets
+}
+
+// socketAddr parses a socket table address, hex words of the address in host byte order
+// followed by a colon and the hex port.
+func socketAddr(s string) (netip.AddrPort, error) {
+	a, p, ok := strings.Cut(s, ":")
+	if !ok || len(a)%8 != 0 {
+		return netip.AddrPort{}, errors.New("invalid socket address " + s)
+	}
+	b := make([]byte, len(a)/2)
+	for i := 0; i < len(a); i += 8 {
+		word, err := strconv.ParseUint(a[i:i+8], 16, 32)
+		if err != nil {
+			return netip.AddrPort{}, err
+		}
+		WriteUint32(b[i/2:], uint32(word))
+	}
+	addr, ok := netip.AddrFromSlice(b)
+	if !ok {
+		return netip.AddrPort{}, errors.New("invalid socket address " + s)
+	}
+	port, err := strconv.ParseUint(p, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), nil
+}
+
 // MountMap builds a map of mount points to file systems.
 func MountMap() (map[string]string, error) {
 	f, err := os.Open("/etc/mtab")
@@ -115,6 +270,58 @@ func Measures(filename string) (map[string]string, error) {
 	return m, nil
 }
 
+// ContainerInfo reports whether the command runs in a container and, if identifiable, the
+// container runtime. The heuristics, in order, are the presence of /.dockerenv (docker) or
+// /run/.containerenv (podman), runtime names in the cgroup paths of /proc/1/cgroup, and the
+// KUBERNETES_SERVICE_HOST environment variable that kubernetes sets in every pod.
+func ContainerInfo() (bool, string, error) {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return true, "docker", nil
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return true, "podman", nil
+	}
+
+	f, err := os.Open("/proc/1/cgroup")
+	if err != nil {
+		return false, "", Error("/proc/1/cgroup open", err)
+	}
+	defer f.Close()
+	if ok, runtime := containerCgroup(f); ok {
+		return true, runtime, nil
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true, "kubernetes", nil
+	}
+	return false, "", nil
+}
+
+// containerCgroup identifies a container runtime from the cgroup paths of a process, read in
+// the /proc/<pid>/cgroup format of hierarchy-ID:controller-list:cgroup-path lines.
+func containerCgroup(r io.Reader) (bool, string) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), ":", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		for _, rt := range [][2]string{
+			{"kubepods", "kubernetes"},
+			{"docker", "docker"},
+			{"libpod", "podman"},
+			{"crio", "cri-o"},
+			{"containerd", "containerd"},
+			{"lxc", "lxc"},
+		} {
+			if strings.Contains(fields[2], rt[0]) {
+				return true, rt[1]
+			}
+		}
+	}
+	return false, ""
+}
+
 // extraFiles called by Spawn to nil fds beyond 2 (stderr) so that they are closed on exec.
 func extraFiles() []*os.File {
 	dirname := filepath.Join("/proc", "self", "fd")
@@ -0,0 +1,45 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatBtime(t *testing.T) {
+	stat := "cpu  1 2 3 4\nintr 5\nctxt 6\nbtime 1700000000\nprocesses 7\n"
+	if bt, err := statBtime(strings.NewReader(stat)); err != nil || !bt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("statBtime() = %v, %v, want %v", bt, err, time.Unix(1700000000, 0))
+	}
+
+	for name, stat := range map[string]string{
+		"btime-less": "cpu  1 2 3 4\nintr 5\nctxt 6\nprocesses 7\n",
+		"truncated":  "cpu  1 2 3 4\nbtime",
+		"empty":      "",
+		"malformed":  "btime soon\n",
+	} {
+		if bt, err := statBtime(strings.NewReader(stat)); err == nil || !bt.IsZero() {
+			t.Errorf("%s: statBtime() = %v, %v, want an error", name, bt, err)
+		}
+	}
+}
+
+func TestUptimeBoot(t *testing.T) {
+	now := time.Unix(1700003600, 500000000)
+	if bt, err := uptimeBoot("3600.25 7200.00\n", now); err != nil || !bt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("uptimeBoot() = %v, %v, want %v", bt, err, time.Unix(1700000000, 0))
+	}
+	for _, s := range []string{"", "later 0"} {
+		if _, err := uptimeBoot(s, now); err == nil {
+			t.Errorf("uptimeBoot(%q) reported no error", s)
+		}
+	}
+}
+
+func TestBoottime(t *testing.T) {
+	if bt := Boottime(); bt.IsZero() || bt.After(time.Now()) {
+		t.Errorf("Boottime() = %v", bt)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSpawnFailures(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		spawn   func() error
+		wantErr error
+	}{
+		{"empty command line", func() error {
+			_, err := Spawn(ctx, nil)
+			return err
+		}, nil},
+		{"stdout pipe", func() error {
+			_, err := Spawn(ctx, []string{"true"}, func(cmd *exec.Cmd) { cmd.Stdout = io.Discard })
+			return err
+		}, nil},
+		{"stderr pipe", func() error {
+			_, _, err := SpawnWithStderr(ctx, []string{"true"}, WithStderr(io.Discard))
+			return err
+		}, nil},
+		{"command not found", func() error {
+			_, err := Spawn(ctx, []string{"gocore-no-such-command"})
+			return err
+		}, ErrCommandNotFound},
+		{"working directory", func() error {
+			_, err := Spawn(ctx, []string{"true"}, WithDir("/gocore-no-such-dir"))
+			return err
+		}, nil},
+		{"session start", func() error {
+			_, err := SpawnSession(ctx, []string{"gocore-no-such-command"})
+			return err
+		}, ErrCommandNotFound},
+	}
+
+	before, err := OpenFDCount()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, tt := range tests {
+		err := tt.spawn()
+		if err == nil {
+			t.Errorf("%s: spawn succeeded", tt.name)
+		} else if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if after, _ := OpenFDCount(); after > before {
+		t.Errorf("open file descriptors %d after failures, %d before", after, before)
+	}
+}
+
+func TestIntToC32(t *testing.T) {
+	for _, n := range []int{0, -1, math.MinInt32, math.MaxInt32} {
+		if v, err := IntToC32(n); err != nil || int(v) != n {
+			t.Errorf("IntToC32(%d) = %d, %v", n, v, err)
+		}
+	}
+	if strconv.IntSize == 64 {
+		for _, n64 := range []int64{math.MinInt32 - 1, math.MaxInt32 + 1, math.MinInt64, math.MaxInt64} {
+			n := int(n64) // conversion at run time, as the constants overflow a 32 bit int
+			if v, err := IntToC32(n); err == nil {
+				t.Errorf("IntToC32(%d) = %d, want overflow", n, v)
+			}
+		}
+	}
+}
+
+func TestIntToCU32(t *testing.T) {
+	for _, n := range []int{0, 1, math.MaxInt32} {
+		if v, err := IntToCU32(n); err != nil || int(v) != n {
+			t.Errorf("IntToCU32(%d) = %d, %v", n, v, err)
+		}
+	}
+	overflows := []int{-1, math.MinInt}
+	if strconv.IntSize == 64 {
+		maxUint32 := uint64(math.MaxUint32)
+		if v, err := IntToCU32(int(maxUint32)); err != nil || v != math.MaxUint32 {
+			t.Errorf("IntToCU32(MaxUint32) = %d, %v", v, err)
+		}
+		overflows = append(overflows, int(maxUint32+1), math.MaxInt)
+	}
+	for _, n := range overflows {
+		if v, err := IntToCU32(n); err == nil {
+			t.Errorf("IntToCU32(%d) = %d, want overflow", n, v)
+		}
+	}
+}
+
+func TestInt64ToInt(t *testing.T) {
+	for _, n := range []int64{0, -1, math.MinInt32, math.MaxInt32, math.MinInt, math.MaxInt} {
+		if v, err := Int64ToInt(n); err != nil || int64(v) != n {
+			t.Errorf("Int64ToInt(%d) = %d, %v", n, v, err)
+		}
+	}
+	if strconv.IntSize == 32 {
+		for _, n := range []int64{math.MinInt32 - 1, math.MaxInt32 + 1, math.MinInt64, math.MaxInt64} {
+			if v, err := Int64ToInt(n); err == nil {
+				t.Errorf("Int64ToInt(%d) = %d, want overflow", n, v)
+			}
+		}
+	}
+}
+
+func TestSessionCloseAfterLineTooLong(t *testing.T) {
+	s, err := SpawnSession(context.Background(), []string{"sh", "-c", "head -c 100000 /dev/zero | tr '\\0' a; echo; seq 1 100000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadLine(); err != bufio.ErrTooLong {
+		t.Fatalf("ReadLine() error %v, want %v", err, bufio.ErrTooLong)
+	}
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on the unread output")
+	}
+}
+
+func TestGoString(t *testing.T) {
+	if s := GoString(&[]byte("abc\x00def")[0]); s != "abc" {
+		t.Errorf("GoString() = %q, want abc", s)
+	}
+	if s := GoString(&[]byte{0}[0]); s != "" {
+		t.Errorf("GoString() of empty = %q", s)
+	}
+	if s := GoString(&[]int8{'x', 'y', 0}[0]); s != "xy" {
+		t.Errorf("GoString() of int8 = %q, want xy", s)
+	}
+}
+
+func TestGoStringN(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		n    int
+		want string
+	}{
+		{"terminated", []byte("abc\x00\x00"), 5, "abc"},
+		{"not terminated", []byte("abc"), 3, "abc"},
+		{"terminated at end", []byte("ab\x00"), 3, "ab"},
+		{"shorter than buffer", []byte("abcdef"), 4, "abcd"},
+		{"empty", []byte{0, 'a'}, 2, ""},
+		{"single byte", []byte("a"), 1, "a"},
+	}
+	for _, tt := range tests {
+		if s := GoStringN(&tt.buf[0], tt.n); s != tt.want {
+			t.Errorf("%s: GoStringN(%q, %d) = %q, want %q", tt.name, tt.buf, tt.n, s, tt.want)
+		}
+	}
+
+	cs := []int8{'h', 'o', 's', 't'}
+	if s := GoStringN(&cs[0], uint32(len(cs))); s != "host" {
+		t.Errorf("GoStringN() of int8 = %q, want host", s)
+	}
+}
+
+func TestSubdirResolved(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "real")
+	if err := os.MkdirAll(filepath.Join(real, "sub", "deeper"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Skip(err)
+	}
+
+	if _, err := Subdir(real, filepath.Join(link, "sub")); err == nil {
+		t.Error("Subdir() accepted a target reached through a symbolic link")
+	}
+	tests := []struct {
+		base, targ, want string
+	}{
+		{real, filepath.Join(link, "sub"), "sub"},
+		{link, filepath.Join(real, "sub", "deeper"), filepath.Join("sub", "deeper")},
+		{link, link, "."},
+	}
+	for _, tt := range tests {
+		if rel, err := SubdirResolved(tt.base, tt.targ); err != nil || rel != tt.want {
+			t.Errorf("SubdirResolved(%s, %s) = %q, %v, want %q", tt.base, tt.targ, rel, err, tt.want)
+		}
+	}
+	if _, err := SubdirResolved(filepath.Join(link, "sub"), real); err == nil {
+		t.Error("SubdirResolved() accepted a target above the base path")
+	}
+	if _, err := SubdirResolved(real, filepath.Join(dir, "missing")); err == nil {
+		t.Error("SubdirResolved() accepted a missing target")
+	}
+}
+
+func TestSpawnCmdReadsAllOutput(t *testing.T) {
+	for range 20 {
+		_, done, sc, err := SpawnCmd(context.Background(), []string{"sh", "-c", "seq 1 20000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for sc.Scan() {
+			n++
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if n != 20000 {
+			t.Fatalf("read %d lines, want 20000", n)
+		}
+	}
+}
+
+func TestSpawnCmdReapsAbandonedCommand(t *testing.T) {
+	cmd, done, sc, err := SpawnCmd(context.Background(), []string{"sh", "-c", "seq 1 1000000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Scan() || sc.Text() != "1" {
+		t.Fatalf("first line %q, %v, want 1", sc.Text(), sc.Err())
+	}
+	sc = nil // stop reading, with most of the output unread
+
+	for range 50 {
+		runtime.GC() // close the abandoned pipe
+		select {
+		case <-done:
+			if cmd.ProcessState == nil {
+				t.Fatal("command not waited for")
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	t.Fatal("abandoned command not reaped")
+}
+
+func TestSpawnCmdCompletesBeforeRead(t *testing.T) {
+	_, done, sc, err := SpawnCmd(context.Background(), []string{"echo", "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("command with unread output not reaped")
+	}
+	if !sc.Scan() || sc.Text() != "hello" {
+		t.Fatalf("output %q, %v, want hello after the command completed", sc.Text(), sc.Err())
+	}
+}
+
+func TestSpawnReadsAllOutput(t *testing.T) {
+	for range 20 {
+		sc, err := Spawn(context.Background(), []string{"sh", "-c", "seq 1 20000"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for sc.Scan() {
+			n++
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if n != 20000 {
+			t.Fatalf("read %d lines, want 20000", n)
+		}
+	}
+}
+
+func TestSpawnWithStderrDrainsStderr(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stdout, stderr, err := SpawnWithStderr(ctx, []string{"sh", "-c", "head -c 200000 /dev/zero >&2; echo done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for stdout.Scan() {
+		lines = append(lines, stdout.Text())
+	}
+	if ctx.Err() != nil {
+		t.Fatal("command blocked writing stderr")
+	}
+	if len(lines) != 1 || lines[0] != "done" {
+		t.Fatalf("stdout %q, want [done]", lines)
+	}
+
+	stderr.Buffer(nil, 300000)
+	stderr.Split(bufio.ScanBytes)
+	var n int
+	for stderr.Scan() {
+		n++
+	}
+	if n != 200000 {
+		t.Fatalf("read %d bytes of stderr, want 200000", n)
+	}
+}
+
+func TestSessionWriteAfterExit(t *testing.T) {
+	s, err := SpawnSession(context.Background(), []string{"head", "-n", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := s.ReadLine(); err != nil || line != "hello" {
+		t.Fatalf("ReadLine() = %q, %v, want hello", line, err)
+	}
+	if _, err := s.ReadLine(); err != io.EOF {
+		t.Fatalf("ReadLine() error %v, want EOF", err)
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := s.Write([]byte("again\n"))
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		if err == nil {
+			t.Fatal("Write to exited command succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write to exited command blocked")
+	}
+	s.Close()
+}
@@ -6,10 +6,21 @@ package gocore
 
 import (
 	"context"
+	"log/syslog"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+type (
+	// syslogWriter writes log messages to syslog at the priorities of their levels.
+	syslogWriter struct {
+		*syslog.Writer
+	}
 )
 
 var (
@@ -19,9 +30,64 @@ var (
 
 // signalContext returns context for detecting interrupt signal.
 func signalContext() (context.Context, context.CancelFunc) {
-	// ignore these signals to enable to continue running
-	signal.Ignore(syscall.SIGWINCH, syscall.SIGHUP, syscall.SIGTTIN, syscall.SIGTTOU)
-	return signal.NotifyContext(context.Background(), os.Interrupt, os.Kill, syscall.SIGTERM)
+	sigs := stopSignals
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, os.Kill, syscall.SIGTERM}
+	}
+
+	// ignore these signals to enable to continue running, unless the command handles them
+	var ignore []os.Signal
+	for _, sig := range []os.Signal{syscall.SIGWINCH, syscall.SIGHUP, syscall.SIGTTIN, syscall.SIGTTOU} {
+		if _, ok := signalHandlers[sig]; !ok && !slices.Contains(sigs, sig) {
+			ignore = append(ignore, sig)
+		}
+	}
+	if len(ignore) > 0 { // signal.Ignore with no signals ignores all
+		signal.Ignore(ignore...)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), sigs...)
+	notifySignals(ctx)
+	return ctx, stop
+}
+
+// SetSyslog routes log messages to syslog rather than to the log output, e.g. for a daemon, with
+// their levels mapped to syslog priorities, until SetLogOutput or SetLogDestination redirects
+// them. Leave network and addr empty to connect to the local syslog server. The message body
+// is the message's Error text; syslog adds the time and tag.
+func SetSyslog(network, addr, tag string) error {
+	w, err := syslog.Dial(network, addr, syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return Error("syslog.Dial", err, map[string]string{
+			"network": network,
+			"address": addr,
+		})
+	}
+
+	logOutput.Lock()
+	if logOutput.syslog != nil {
+		logOutput.syslog.Close()
+	}
+	logOutput.syslog = syslogWriter{w}
+	logOutput.Unlock()
+	return nil
+}
+
+// write writes a log message to syslog at the priority of its level.
+func (w syslogWriter) write(msg LogMessage, level LogLevel) {
+	text := msg.Error()
+	switch level {
+	case LevelFatal:
+		w.Crit(text)
+	case LevelError:
+		w.Err(text)
+	case LevelWarn:
+		w.Warning(text)
+	case LevelInfo:
+		w.Info(text)
+	default:
+		w.Debug(text)
+	}
 }
 
 // seteuid current process to file owner.
@@ -41,3 +107,29 @@ func Setuid() {
 		"euid": strconv.Itoa(os.Geteuid()),
 	}).Info()
 }
+
+// quietStderr calls fn with standard error redirected to the null device, to suppress a message
+// that the runtime writes directly to file descriptor 2. Log messages wait until it is restored.
+func quietStderr(fn func()) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		fn()
+		return
+	}
+	defer null.Close()
+	stderr, err := unix.Dup(2)
+	if err != nil {
+		fn()
+		return
+	}
+	defer unix.Close(stderr)
+
+	logOutput.Lock()
+	defer logOutput.Unlock()
+	if err := unix.Dup2(int(null.Fd()), 2); err != nil {
+		fn()
+		return
+	}
+	defer unix.Dup2(stderr, 2)
+	fn()
+}
@@ -0,0 +1,79 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+//go:build !windows
+
+package gocore
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSyslogUntilSetLogOutput(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	defer SetLogOutput(logOutput.Writer)
+
+	if err := SetSyslog("unixgram", addr, "gocore"); err != nil {
+		t.Fatal(err)
+	}
+	Error("syslog test", errors.New("to syslog")).Err()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1024)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b[:n]); !strings.HasPrefix(got, "<27>") || !strings.Contains(got, `err="to syslog"`) {
+		t.Errorf("syslog received %q, want priority <27> (daemon, err) and the message", got)
+	}
+
+	var buf bytes.Buffer
+	SetLogOutput(&buf)
+	Error("syslog test", errors.New("to output")).Err()
+	if !strings.Contains(buf.String(), `err="to output"`) {
+		t.Errorf("log output %q after SetLogOutput, want the message", buf.String())
+	}
+}
+
+func TestQuietStderr(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stderr, err := unix.Dup(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(stderr)
+	if err := unix.Dup2(int(f.Fd()), 2); err != nil {
+		t.Fatal(err)
+	}
+	unix.Write(2, []byte("before\n"))
+	quietStderr(func() {
+		unix.Write(2, []byte("quiet\n"))
+	})
+	unix.Write(2, []byte("after\n"))
+	unix.Dup2(stderr, 2)
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "before\nafter\n" {
+		t.Errorf("stderr %q, want %q", got, "before\nafter\n")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"time"
 	"unsafe"
@@ -15,9 +16,24 @@ import (
 	"golang.org/x/sys/windows"
 )
 
+type (
+	// sid is key to cached account name of a security identifier.
+	sid string
+
+	// account is the name and type of the account of a security identifier.
+	account struct {
+		name string
+		use  uint32 // e.g. windows.SidTypeUser
+	}
+)
+
 var (
+	// snames is the cache of accounts by security identifier.
+	snames = NewCache(lookupAccount, 0)
+
 	kernel32                 = windows.NewLazySystemDLL("kernel32.dll")
 	getFinalPathNameByHandle = kernel32.NewProc("GetFinalPathNameByHandleW").Call
+	getProcessHandleCount    = kernel32.NewProc("GetProcessHandleCount").Call
 
 	// DriveTypes maps DRIVE keys to names.
 	DriveTypes = map[uint32]string{
@@ -29,15 +45,6 @@ var (
 		windows.DRIVE_CDROM:       "cdrom",
 		windows.DRIVE_RAMDISK:     "ramdisk",
 	}
-
-	// boottime gets the system boot time.
-	Boottime = func() time.Time {
-		wos := []win32OperatingSystem{}
-		if wmi.Query(wmi.CreateQuery(&wos, ""), &wos) == nil {
-			return wos[0].LastBootUpTime
-		}
-		return time.Time{}
-	}()
 )
 
 const (
@@ -45,9 +52,70 @@ const (
 	volumeNameNT  = 2
 )
 
+// boottime gets the system boot time.
+func boottime() time.Time {
+	wos := []win32OperatingSystem{}
+	if wmi.Query(wmi.CreateQuery(&wos, ""), &wos) == nil && len(wos) > 0 {
+		return wos[0].LastBootUpTime
+	}
+	return time.Time{}
+}
+
 // signalContext returns context for detecting interrupt signal.
 func signalContext() (context.Context, context.CancelFunc) {
-	return signal.NotifyContext(context.Background(), os.Interrupt)
+	sigs := stopSignals
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), sigs...)
+	notifySignals(ctx)
+	return ctx, stop
+}
+
+// UsernameSID retrieves and caches the account name, as domain\name, of a user's security
+// identifier string, e.g. S-1-5-21-...-1001. Windows identifies users by SID rather than uid.
+func UsernameSID(s string) string {
+	return accountname(s, false)
+}
+
+// GroupnameSID retrieves and caches the account name, as domain\name, of a group's security
+// identifier string, e.g. S-1-5-32-544.
+func GroupnameSID(s string) string {
+	return accountname(s, true)
+}
+
+// accountname returns the account name of a security identifier of a group or not, or the
+// security identifier itself if it does not identify an account of that kind.
+func accountname(s string, group bool) string {
+	value, _ := snames.Lookup(sid(s))
+	switch value.use {
+	case windows.SidTypeGroup, windows.SidTypeWellKnownGroup, windows.SidTypeAlias:
+		if group {
+			return value.name
+		}
+	case 0: // not found
+	default:
+		if !group {
+			return value.name
+		}
+	}
+	return s
+}
+
+// lookupAccount retrieves the account of a security identifier.
+func lookupAccount(s sid) (account, error) {
+	id, err := windows.StringToSid(string(s))
+	if err != nil {
+		return account{name: string(s)}, nil
+	}
+	name, domain, use, err := id.LookupAccount("")
+	if err != nil {
+		return account{name: string(s)}, nil
+	}
+	if domain != "" {
+		name = domain + `\` + name
+	}
+	return account{name: name, use: use}, nil
 }
 
 // FdPath gets the path for an open file descriptor
@@ -68,9 +136,85 @@ func FdPath(fd int) (string, error) {
 	return path, nil
 }
 
-// MountMap builds a map of mount points to file systems.
+// Processes lists the process ids of all processes on the system.
+func Processes() ([]int, error) {
+	ids := make([]uint32, 1024)
+	for {
+		var n uint32
+		if err := windows.EnumProcesses(ids, &n); err != nil {
+			return nil, Error("EnumProcesses", err)
+		}
+		if int(n) < len(ids)*4 { // all process ids returned
+			ids = ids[:n/4]
+			break
+		}
+		ids = make([]uint32, 2*len(ids))
+	}
+	pids := make([]int, len(ids))
+	for i, id := range ids {
+		pids[i] = int(id)
+	}
+	return pids, nil
+}
+
+// OpenFDCount counts the open handles of this process.
+func OpenFDCount() (int, error) {
+	var count uint32
+	if rv, _, err := getProcessHandleCount(
+		uintptr(windows.CurrentProcess()),
+		uintptr(unsafe.Pointer(&count)),
+	); rv == 0 {
+		return 0, Error("GetProcessHandleCount", err)
+	}
+	return int(count), nil
+}
+
+// ProcessSockets reports the internet sockets held open by a process.
+func ProcessSockets(pid int) ([]SocketInfo, error) {
+	return nil, Unsupported()
+}
+
+// MountMap builds a map of mount points (drive roots, e.g. C:\) to file systems (DOS devices).
 func MountMap() (map[string]string, error) {
-	return map[string]string{}, Unsupported()
+	var buf [26*4 + 1]uint16 // room for all drive roots, each "X:\" and a null
+	n, err := windows.GetLogicalDriveStrings(uint32(len(buf)), &buf[0])
+	if n == 0 || int(n) > len(buf) {
+		return nil, Error("GetLogicalDriveStrings", err)
+	}
+
+	m := map[string]string{}
+	for drives := buf[:n]; len(drives) > 0; {
+		i := slices.Index(drives, 0)
+		if i <= 0 {
+			break
+		}
+		root := windows.UTF16ToString(drives[:i])
+		drives = drives[i+1:]
+
+		p, _ := windows.UTF16PtrFromString(root)
+		switch windows.GetDriveType(p) {
+		case windows.DRIVE_UNKNOWN, windows.DRIVE_NO_ROOT_DIR:
+			continue
+		}
+
+		m[root] = root
+		var device [windows.MAX_PATH + 1]uint16
+		p, _ = windows.UTF16PtrFromString(strings.TrimSuffix(root, `\`))
+		if n, err := windows.QueryDosDevice(p, &device[0], uint32(len(device))); err == nil && n > 0 {
+			m[root] = windows.UTF16ToString(device[:n])
+		}
+	}
+	return m, nil
+}
+
+// SetSyslog routes log messages to syslog, which is not supported on Windows.
+func SetSyslog(network, addr, tag string) error {
+	return Unsupported()
+}
+
+// ContainerInfo reports whether the command runs in a container, which is not supported on Windows.
+func ContainerInfo() (bool, string, error) {
+	return false, "", Unsupported()
 }
 
 // Win32_OperatingSystem is a WMI Class for operating system information.
@@ -81,6 +225,12 @@ type win32OperatingSystem struct {
 	LastBootUpTime time.Time // Field names in the structure must match names in the WMI Class
 }
 
+// quietStderr calls fn, as the runtime writes its messages to the standard error handle that the
+// process started with.
+func quietStderr(fn func()) {
+	fn()
+}
+
 // extraFiles called by Spawn to nil fds beyond 2 (stderr) so that they are closed on exec.
 func extraFiles() []*os.File {
 	return nil
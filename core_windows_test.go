@@ -0,0 +1,25 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"testing"
+)
+
+func TestAccountnameKind(t *testing.T) {
+	const administrators = "S-1-5-32-544" // BUILTIN\Administrators, localized
+	if name := GroupnameSID(administrators); name == administrators {
+		t.Errorf("GroupnameSID(%s) found no group", administrators)
+	}
+	if name := UsernameSID(administrators); name != administrators {
+		t.Errorf("UsernameSID(%s) = %s, the name of a group", administrators, name)
+	}
+	for _, s := range []string{"not a sid", "S-1-5-21-1-2-3-999999"} {
+		if name := UsernameSID(s); name != s {
+			t.Errorf("UsernameSID(%q) = %q, want the SID", s, name)
+		}
+		if name := GroupnameSID(s); name != s {
+			t.Errorf("GroupnameSID(%q) = %q, want the SID", s, name)
+		}
+	}
+}
@@ -8,8 +8,21 @@ Package gocore implements functionality used by commands. Functions support
   - enhanced logging
 
 The gocore package defines the following command line flags:
-  - -version:    to report the current version of the command
-  - -cpuprofile: profile CPU performance of command
-  - -memprofile: profile memory usage of command
+  - -version:          to report the current version of the command, as text or JSON
+  - -cpuprofile:       profile CPU performance of command
+  - -cpuprofile-rate:  set the CPU profile sampling rate
+  - -memprofile:       profile memory usage of command
+  - -blockprofile:     profile goroutine blocking of command
+  - -mutexprofile:     profile mutex contention of command
+  - -goroutineprofile: profile goroutine stacks at exit of command
+  - -trace:            trace execution of command
+  - -profiledir:       write profiles to this directory
+  - -cleanup-profiles: remove profiles of previous invocations of command
+  - -loglevel:         set the minimum level of messages logged
+
+The gocore package reads the following environment variables, prefixed with the prefix set by
+EnvPrefix, if any, e.g. GOMON_RECORD_STDIN:
+  - RECORD_STDIN: record stdin to this file, for replay
+  - REPLAY_STDIN: read stdin from this file, e.g. one recorded
 */
 package gocore
@@ -0,0 +1,117 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"slices"
+	"sync"
+)
+
+type (
+	// EventBus delivers the events published by a command's components to their subscribers.
+	// Its zero value is ready for use, and it is safe for concurrent use.
+	EventBus[T any] struct {
+		mu   sync.RWMutex
+		subs []*subscription[T]
+		chs  sync.Map // subscriptions by channel, for Unsubscribe to find without the lock
+	}
+
+	// subscription is a subscriber's event channel and its unsubscribe signal. Publishers
+	// hold the read lock while they send, so that the channel is not closed under them.
+	subscription[T any] struct {
+		mu   sync.RWMutex
+		ch   chan T
+		done chan struct{}
+		once sync.Once
+	}
+)
+
+const (
+	// eventBuffer is the number of events buffered for each subscriber.
+	eventBuffer = 16
+)
+
+// Subscribe returns a channel for receiving the events published to the bus.
+func (bus *EventBus[T]) Subscribe() <-chan T {
+	sub := &subscription[T]{
+		ch:   make(chan T, eventBuffer),
+		done: make(chan struct{}),
+	}
+	bus.chs.Store((<-chan T)(sub.ch), sub)
+	bus.mu.Lock()
+	bus.subs = append(bus.subs, sub)
+	bus.mu.Unlock()
+	return sub.ch
+}
+
+// Unsubscribe stops delivery of events to a channel returned by Subscribe, and closes it.
+func (bus *EventBus[T]) Unsubscribe(ch <-chan T) {
+	v, ok := bus.chs.LoadAndDelete(ch)
+	if !ok {
+		return
+	}
+	sub := v.(*subscription[T])
+	sub.once.Do(func() { close(sub.done) }) // release publishers blocked on the subscriber
+
+	bus.mu.Lock()
+	if i := slices.Index(bus.subs, sub); i >= 0 {
+		bus.subs = slices.Delete(bus.subs, i, i+1)
+	}
+	bus.mu.Unlock()
+
+	sub.mu.Lock()
+	close(sub.ch)
+	sub.mu.Unlock()
+}
+
+// Publish delivers an event to every subscriber, waiting for each to have room for it.
+func (bus *EventBus[T]) Publish(event T) {
+	for _, sub := range bus.subscriptions() {
+		sub.send(event, true)
+	}
+}
+
+// TryPublish delivers an event to every subscriber that has room for it, so that a slow
+// subscriber does not stall the publisher. It returns the number of subscribers that missed it.
+func (bus *EventBus[T]) TryPublish(event T) int {
+	var missed int
+	for _, sub := range bus.subscriptions() {
+		if !sub.send(event, false) {
+			missed++
+		}
+	}
+	return missed
+}
+
+// subscriptions copies the subscriptions, so that publishers send without the bus's lock.
+func (bus *EventBus[T]) subscriptions() []*subscription[T] {
+	bus.mu.RLock()
+	defer bus.mu.RUnlock()
+	return slices.Clone(bus.subs)
+}
+
+// send delivers an event to a subscriber that has not unsubscribed. Unless wait is set, send
+// does not wait for the subscriber to have room, and returns false if the subscriber missed it.
+func (sub *subscription[T]) send(event T, wait bool) bool {
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+	select {
+	case <-sub.done:
+		return true // channel is closed or about to be
+	default:
+	}
+	if wait {
+		select {
+		case sub.ch <- event:
+		case <-sub.done:
+		}
+		return true
+	}
+	select {
+	case sub.ch <- event:
+	case <-sub.done:
+	default:
+		return false
+	}
+	return true
+}
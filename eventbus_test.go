@@ -0,0 +1,85 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUnsubscribeReleasesBlockedPublish(t *testing.T) {
+	var bus EventBus[int]
+	ch := bus.Subscribe()
+	for i := range eventBuffer {
+		if missed := bus.TryPublish(i); missed != 0 {
+			t.Fatalf("TryPublish() missed %d subscribers", missed)
+		}
+	}
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(eventBuffer) // blocks on the full subscriber
+		close(published)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	subscribed := make(chan (<-chan int))
+	go func() {
+		subscribed <- bus.Subscribe()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	unsubscribed := make(chan struct{})
+	go func() {
+		bus.Unsubscribe(ch)
+		close(unsubscribed)
+	}()
+	for name, done := range map[string]chan struct{}{"Unsubscribe": unsubscribed, "Publish": published} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s blocked", name)
+		}
+	}
+	select {
+	case other := <-subscribed:
+		bus.Unsubscribe(other)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe blocked")
+	}
+
+	var n int
+	for range ch {
+		n++
+	}
+	if n != eventBuffer {
+		t.Errorf("received %d events, want %d", n, eventBuffer)
+	}
+}
+
+func TestUnsubscribeDuringPublish(t *testing.T) {
+	var bus EventBus[int]
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				bus.Publish(i)
+				bus.TryPublish(i)
+			}
+		}
+	}()
+	for range 20 {
+		ch := bus.Subscribe()
+		<-ch
+		bus.Unsubscribe(ch)
+		bus.Unsubscribe(ch) // no effect
+	}
+	close(stop)
+	wg.Wait()
+}
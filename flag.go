@@ -8,6 +8,8 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -19,12 +21,33 @@ import (
 type (
 	flags struct {
 		flag.FlagSet
-		version              bool
+		version              versionFormat
 		cpuprofile           bool
+		cpuprofileRate       int
 		memprofile           bool
+		blockprofile         bool
+		mutexprofile         bool
+		goroutineprofile     bool
+		trace                bool
+		profiledir           string
+		cleanupProfiles      bool
+		loglevel             LogLevel
 		CommandDescription   string
 		ArgumentDescriptions [][2]string
+		Strict               bool // report all undefined flags, with suggestions, rather than only the first
+		Interspersed         bool // recognize flags following arguments, e.g. cmd file.txt -version
 		argsMax              int
+		required             []string
+		envPrefix            string
+		completion           string
+		hidden               map[string]bool // flags omitted from usage
+		groups               []flagGroup
+	}
+
+	// flagGroup names a section of usage for a group of flags.
+	flagGroup struct {
+		name  string
+		flags []string
 	}
 )
 
@@ -32,11 +55,21 @@ var (
 	// Flags defines and initializes the command line flags
 	Flags = flags{
 		FlagSet:              flag.FlagSet{},
-		version:              false,
+		version:              "",
 		cpuprofile:           false,
+		cpuprofileRate:       0,
 		memprofile:           false,
+		blockprofile:         false,
+		mutexprofile:         false,
+		goroutineprofile:     false,
+		trace:                false,
+		profiledir:           "",
+		cleanupProfiles:      false,
+		loglevel:             GetLoggingLevel(),
 		CommandDescription:   "",
 		ArgumentDescriptions: [][2]string{},
+		Strict:               false,
+		Interspersed:         false,
 		argsMax:              0,
 	}
 
@@ -49,6 +82,9 @@ var (
 
 // Var maps a flag field to its name and description, and adds a brief description
 func (f *flags) Var(field any, name, syntax, detail string) {
+	if field, ok := field.(interface{ syntax(string) string }); ok && syntax == "" {
+		syntax = field.syntax(name)
+	}
 	flagSyntax[name] = syntax
 	switch field := field.(type) {
 	case *int:
@@ -72,6 +108,28 @@ func (f *flags) Var(field any, name, syntax, detail string) {
 	}
 }
 
+// Require declares flags that must be set on the command line.
+func (f *flags) Require(names ...string) {
+	f.required = append(f.required, names...)
+}
+
+// EnvPrefix sets flags not set on the command line from environment variables named by the
+// prefix and the flag name, uppercased with dashes replaced by underscores, e.g. GOMON_LOGLEVEL.
+func (f *flags) EnvPrefix(prefix string) {
+	f.envPrefix = prefix
+}
+
+// Group lists flags under a section of usage. Flags not grouped are listed under OPTIONS.
+func (f *flags) Group(name string, names ...string) {
+	for i := range f.groups {
+		if f.groups[i].name == name {
+			f.groups[i].flags = append(f.groups[i].flags, names...)
+			return
+		}
+	}
+	f.groups = append(f.groups, flagGroup{name: name, flags: names})
+}
+
 // init initializes the gocore command line flags.
 func init() {
 	log.SetFlags(0)
@@ -79,8 +137,8 @@ func init() {
 	Flags.Var(
 		&Flags.version,
 		"version",
-		"[-version]",
-		"Print version information and exit",
+		"[-version[=json]]",
+		"Print version information, as text or with =json as JSON, and exit",
 	)
 
 	Flags.Var(
@@ -90,6 +148,13 @@ func init() {
 		"Capture a CPU performance profile for this invocation",
 	)
 
+	Flags.Var(
+		&Flags.cpuprofileRate,
+		"cpuprofile-rate",
+		"[-cpuprofile-rate hz]",
+		"Sample the CPU performance profile at `hz` samples per second rather than 100",
+	)
+
 	Flags.Var(
 		&Flags.memprofile,
 		"memprofile",
@@ -97,16 +162,114 @@ func init() {
 		"Capture a memory usage profile for this invocation",
 	)
 
+	Flags.Var(
+		&Flags.blockprofile,
+		"blockprofile",
+		"[-blockprofile]",
+		"Capture a goroutine blocking profile for this invocation",
+	)
+
+	Flags.Var(
+		&Flags.mutexprofile,
+		"mutexprofile",
+		"[-mutexprofile]",
+		"Capture a mutex contention profile for this invocation",
+	)
+
+	Flags.Var(
+		&Flags.goroutineprofile,
+		"goroutineprofile",
+		"[-goroutineprofile]",
+		"Capture the goroutine stacks at exit of this invocation",
+	)
+
+	Flags.Var(
+		&Flags.trace,
+		"trace",
+		"[-trace]",
+		"Capture an execution trace for this invocation",
+	)
+
+	Flags.Var(
+		&Flags.profiledir,
+		"profiledir",
+		"[-profiledir path]",
+		"Write profiles to the directory at `path` rather than the defaults",
+	)
+
+	Flags.Var(
+		&Flags.cleanupProfiles,
+		"cleanup-profiles",
+		"[-cleanup-profiles]",
+		"Remove the profiles written by previous invocations of this command",
+	)
+
+	Flags.Var(
+		&Flags.loglevel,
+		"loglevel",
+		"[-loglevel trace|debug|info|warn|error|fatal]",
+		"Log messages at or above this `level`, overriding the LOG_LEVEL environment variable",
+	)
+
+	Flags.FlagSet.Var(
+		(*completionShell)(&Flags.completion),
+		"completion",
+		"Print a completion script for `shell` bash or zsh and exit",
+	)
+	Flags.hidden = map[string]bool{"completion": true}
+
+	Flags.Group(
+		"Diagnostics",
+		"version",
+		"cpuprofile",
+		"cpuprofile-rate",
+		"memprofile",
+		"blockprofile",
+		"mutexprofile",
+		"goroutineprofile",
+		"trace",
+		"profiledir",
+		"cleanup-profiles",
+		"loglevel",
+	)
+
 	Flags.SetOutput(&logBuf) // capture FlagSet.Parse messages
 	Flags.Usage = usage
 }
 
 // parse inspects the command line.
 func parse(args []string) error {
+	if Flags.Interspersed {
+		args = permute(args) // before the strict check, so that it finds flags following arguments
+	}
+
+	if Flags.Strict {
+		if err := undefined(args); err != nil {
+			return Error("argument parser", err)
+		}
+	}
+
 	if err := Flags.Parse(args); err != nil {
 		return Error("argument parser", err)
 	}
 
+	if err := environment(); err != nil {
+		return Error("argument parser", err)
+	}
+
+	if err := missing(); err != nil {
+		return Error("argument parser", err)
+	}
+
+	if LoggingLevel != requestedLevel { // assigned before Main by a caller of the deprecated variable
+		SetLoggingLevel(LoggingLevel)
+	}
+	Flags.Visit(func(f *flag.Flag) {
+		if f.Name == "loglevel" { // otherwise keep the level set by LOG_LEVEL or SetLoggingLevel
+			SetLoggingLevel(Flags.loglevel)
+		}
+	})
+
 	if Flags.NArg() > Flags.argsMax { // too many arguments?
 		args := strings.Join(Flags.Args()[Flags.NArg()-Flags.argsMax-1:], " ")
 		return Error("argument parser", fmt.Errorf("%s", args))
@@ -115,9 +278,203 @@ func parse(args []string) error {
 	return nil
 }
 
+// environment sets the flags not set on the command line from their environment variables.
+func environment() error {
+	if Flags.envPrefix == "" {
+		return nil
+	}
+	set := map[string]bool{}
+	Flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var errs []error
+	Flags.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] {
+			return // command line wins
+		}
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := Flags.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for environment variable %s: %w", value, name, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// envName names an environment variable of the command, prefixed with the prefix set by
+// EnvPrefix, if any, and uppercased with dashes replaced by underscores.
+func envName(name string) string {
+	if Flags.envPrefix != "" {
+		name = Flags.envPrefix + "_" + name
+	}
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// missing reports the required flags that are not set on the command line, unless the command
+// is invoked only to report information such as its version.
+func missing() error {
+	if Flags.version != "" || Flags.completion != "" {
+		return nil
+	}
+	set := map[string]bool{}
+	Flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var names []string
+	for _, name := range Flags.required {
+		if !set[name] {
+			names = append(names, "-"+name)
+		}
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("missing required flags %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// Completion generates a script for the bash or zsh shell that completes the command's flags.
+func (f *flags) Completion(shell string) (string, error) {
+	cmd := filepath.Base(Executable)
+	var sb strings.Builder
+	switch shell {
+	case "bash":
+		var names []string
+		f.VisitAll(func(fl *flag.Flag) {
+			if !f.hidden[fl.Name] {
+				names = append(names, "-"+fl.Name)
+			}
+		})
+		names = append(names, "-help")
+		fn := "_" + strings.Map(func(r rune) rune {
+			if r == '-' || r == '.' {
+				return '_'
+			}
+			return r
+		}, cmd)
+		fmt.Fprintf(&sb, "%s() {\n", fn)
+		fmt.Fprintf(&sb, "\tCOMPREPLY=($(compgen -W %q -- \"${COMP_WORDS[COMP_CWORD]}\"))\n", strings.Join(names, " "))
+		fmt.Fprintf(&sb, "}\ncomplete -o default -F %s %s\n", fn, cmd)
+	case "zsh":
+		fmt.Fprintf(&sb, "#compdef %s\n\n_arguments \\\n", cmd)
+		f.VisitAll(func(fl *flag.Flag) {
+			if f.hidden[fl.Name] {
+				return
+			}
+			_, usage := flag.UnquoteUsage(fl)
+			usage, _, _ = strings.Cut(usage, "\n")
+			usage = strings.NewReplacer("'", "'\\''", "[", "\\[", "]", "\\]").Replace(usage)
+			fmt.Fprintf(&sb, "  '-%s[%s]' \\\n", fl.Name, usage)
+		})
+		sb.WriteString("  '-help[Print the help and exit]'\n")
+	default:
+		return "", fmt.Errorf("unsupported shell %q, valid shells are bash, zsh", shell)
+	}
+	return sb.String(), nil
+}
+
+// permute moves the flags of a command line ahead of its arguments, preserving the order of
+// each, so that the flag package recognizes flags anywhere on the line. Arguments following
+// "--" are not permuted.
+func permute(args []string) []string {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name, _, value := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if f := Flags.Lookup(name); f != nil && !value && i+1 < len(args) {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++
+				flags = append(flags, args[i]) // the flag's value
+			}
+		}
+	}
+	if len(positional) > 0 {
+		flags = append(flags, "--")
+	}
+	return append(flags, positional...)
+}
+
+// undefined scans the command line for flags that are not defined, suggesting the nearest
+// defined flag for each. As the value of an undefined flag is indeterminate, a following
+// argument that does not begin with "-" is assumed to be its value.
+func undefined(args []string) error {
+	var errs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" { // flag parsing stops here
+			break
+		}
+		name, _, value := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "help" || name == "h" {
+			continue
+		}
+		if f := Flags.Lookup(name); f != nil {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !value && !(ok && b.IsBoolFlag()) {
+				i++ // skip the flag's value
+			}
+			continue
+		}
+		e := "unknown flag -" + name
+		if s := suggest(name); s != "" {
+			e += ", did you mean -" + s + "?"
+		}
+		errs = append(errs, e)
+		if !value && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// suggest finds the defined flag name nearest by edit distance to an undefined name.
+func suggest(name string) string {
+	var nearest string
+	least := max(2, len(name)/3) + 1 // suggest only reasonably close names
+	Flags.VisitAll(func(f *flag.Flag) {
+		if d := distance(name, f.Name); d < least {
+			nearest, least = f.Name, d
+		}
+	})
+	return nearest
+}
+
+// distance computes the Levenshtein edit distance between two strings.
+func distance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range s {
+		curr[0] = i + 1
+		for j := range t {
+			cost := 1
+			if s[i] == t[j] {
+				cost = 0
+			}
+			curr[j+1] = min(prev[j+1]+1, curr[j]+1, prev[j]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
 // usage formats the flags Usage message for gomon.
 func usage() {
-	if !IsTerminal(os.Stderr) && logBuf.Len() > 0 { // if called by go's flag package parser, may have error text
+	if !IsTerminal(console()) && logBuf.Len() > 0 { // if called by go's flag package parser, may have error text
 		Error("terminal", errors.New(strings.TrimSpace(logBuf.String()))).Err() // in that case report it
 		return
 	}
@@ -127,7 +484,9 @@ func usage() {
 
 	var names []string
 	for name := range flagSyntax {
-		names = append(names, name)
+		if !Flags.hidden[name] {
+			names = append(names, name)
+		}
 	}
 	slices.Sort(names)
 	var flags []string
@@ -150,7 +509,22 @@ OPTIONS:
   -help
 	Print the help and exit
 `)
-	Flags.PrintDefaults()
+	for _, name := range Flags.required {
+		if f := Flags.Lookup(name); f != nil && !strings.HasSuffix(f.Usage, " (required)") {
+			f.Usage += " (required)"
+		}
+	}
+	grouped := map[string]bool{}
+	for _, group := range Flags.groups {
+		for _, name := range group.flags {
+			grouped[name] = true
+		}
+	}
+	printDefaults(func(name string) bool { return !grouped[name] })
+	for _, group := range Flags.groups {
+		logBuf.WriteString("\n" + strings.ToUpper(group.name) + ":\n")
+		printDefaults(func(name string) bool { return slices.Contains(group.flags, name) })
+	}
 
 	if len(Flags.ArgumentDescriptions) > 0 {
 		logBuf.WriteString("\nARGUMENTS:\n")
@@ -159,7 +533,72 @@ OPTIONS:
 		}
 	}
 	logBuf.WriteString("\nCopyright © 2023 The Gomon Project.\n")
-	fmt.Fprint(os.Stderr, logBuf.String())
+	fmt.Fprint(console(), logBuf.String())
+}
+
+// printDefaults writes the usage of each selected flag that is not hidden.
+func printDefaults(selected func(string) bool) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(&logBuf)
+	Flags.VisitAll(func(f *flag.Flag) {
+		if !Flags.hidden[f.Name] && selected(f.Name) {
+			fs.Var(f.Value, f.Name, f.Usage)
+			fs.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	fs.PrintDefaults()
+}
+
+// completionShell is the value of the -completion flag, validating the shell named.
+type completionShell string
+
+// Set is a flag.Value interface method to enable completionShell as a command line flag.
+func (cs *completionShell) Set(shell string) error {
+	if shell != "bash" && shell != "zsh" {
+		return errors.New("valid shells are bash, zsh")
+	}
+	*cs = completionShell(shell)
+	return nil
+}
+
+// String is a flag.Value interface method to enable completionShell as a command line flag.
+func (cs *completionShell) String() string {
+	if cs == nil {
+		return ""
+	}
+	return string(*cs)
+}
+
+// versionFormat is the value of the -version flag, the format of the version information
+// printed: text for a bare -version, or json.
+type versionFormat string
+
+// Set is a flag.Value interface method to enable versionFormat as a command line flag.
+func (vf *versionFormat) Set(format string) error {
+	switch format {
+	case "true", "text":
+		*vf = "text"
+	case "false":
+		*vf = ""
+	case "json":
+		*vf = "json"
+	default:
+		return errors.New("valid formats are text, json")
+	}
+	return nil
+}
+
+// String is a flag.Value interface method to enable versionFormat as a command line flag.
+func (vf *versionFormat) String() string {
+	if vf == nil {
+		return ""
+	}
+	return string(*vf)
+}
+
+// IsBoolFlag is a flag package interface method that enables -version without a value.
+func (vf *versionFormat) IsBoolFlag() bool {
+	return true
 }
 
 // Regexp is a command line flag type.
@@ -180,3 +619,182 @@ func (r *Regexp) String() string {
 	}
 	return r.Regexp.String()
 }
+
+// StringSlice is a command line flag type for a list of values, specified as comma separated
+// values and/or by repeating the flag, e.g. -tags a,b -tags c.
+type StringSlice []string
+
+// Set is a flag.Value interface method to enable StringSlice as a command line flag.
+func (ss *StringSlice) Set(values string) error {
+	*ss = append(*ss, strings.Split(values, ",")...)
+	return nil
+}
+
+// String is a flag.Value interface method to enable StringSlice as a command line flag.
+func (ss *StringSlice) String() string {
+	if ss == nil {
+		return ""
+	}
+	return strings.Join(*ss, ",")
+}
+
+// syntax formats the command line syntax of a StringSlice flag for usage.
+func (ss *StringSlice) syntax(name string) string {
+	return "[-" + name + " value,...]"
+}
+
+// Enum is a command line flag type for a value constrained to a set of valid values.
+type Enum[T ~string] struct {
+	Value T
+	valid ValidValue[T]
+}
+
+// NewEnum creates an Enum flag with its valid values and default value.
+func NewEnum[T ~string](valid ValidValue[T], value T) *Enum[T] {
+	return &Enum[T]{
+		Value: value,
+		valid: valid,
+	}
+}
+
+// Set is a flag.Value interface method to enable Enum as a command line flag.
+func (e *Enum[T]) Set(value string) error {
+	v, err := e.valid.Parse(value)
+	if err != nil {
+		return err
+	}
+	e.Value = v
+	return nil
+}
+
+// String is a flag.Value interface method to enable Enum as a command line flag.
+func (e *Enum[T]) String() string {
+	if e == nil {
+		return ""
+	}
+	return string(e.Value)
+}
+
+// syntax formats the command line syntax of an Enum flag for usage.
+func (e *Enum[T]) syntax(name string) string {
+	return "[-" + name + " " + strings.Join(e.valid.ValidValues(), "|") + "]"
+}
+
+// FileFlag is a command line flag type for a path to an existing, readable file, which is
+// opened when the flag is set.
+type FileFlag struct {
+	Path string
+	File *os.File
+}
+
+// Set is a flag.Value interface method to enable FileFlag as a command line flag.
+func (ff *FileFlag) Set(path string) error {
+	path, err := filepath.Abs(path)
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if ff.File != nil { // flag repeated
+		ff.File.Close()
+	}
+	ff.Path, ff.File = path, f
+	return nil
+}
+
+// String is a flag.Value interface method to enable FileFlag as a command line flag.
+func (ff *FileFlag) String() string {
+	if ff == nil {
+		return ""
+	}
+	return ff.Path
+}
+
+// syntax formats the command line syntax of a FileFlag flag for usage.
+func (ff *FileFlag) syntax(name string) string {
+	return "[-" + name + " path]"
+}
+
+// URLFlag is a command line flag type for an absolute URL, such as an endpoint.
+type URLFlag struct {
+	*url.URL
+}
+
+// Set is a flag.Value interface method to enable URLFlag as a command line flag.
+func (u *URLFlag) Set(rawURL string) error {
+	parsed, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme == "" {
+		return errors.New("URL has no scheme")
+	}
+	u.URL = parsed
+	return nil
+}
+
+// String is a flag.Value interface method to enable URLFlag as a command line flag.
+func (u *URLFlag) String() string {
+	if u == nil || u.URL == nil {
+		return ""
+	}
+	return u.URL.String()
+}
+
+// syntax formats the command line syntax of a URLFlag flag for usage.
+func (u *URLFlag) syntax(name string) string {
+	return "[-" + name + " scheme://host[:port]/path]"
+}
+
+// AddrFlag is a command line flag type for an IP address.
+type AddrFlag struct {
+	netip.Addr
+}
+
+// Set is a flag.Value interface method to enable AddrFlag as a command line flag.
+func (a *AddrFlag) Set(addr string) (err error) {
+	a.Addr, err = netip.ParseAddr(addr)
+	return
+}
+
+// String is a flag.Value interface method to enable AddrFlag as a command line flag.
+func (a *AddrFlag) String() string {
+	if a == nil || !a.Addr.IsValid() {
+		return ""
+	}
+	return a.Addr.String()
+}
+
+// syntax formats the command line syntax of an AddrFlag flag for usage.
+func (a *AddrFlag) syntax(name string) string {
+	return "[-" + name + " address]"
+}
+
+// PrefixFlag is a command line flag type for an IP network prefix in CIDR notation.
+type PrefixFlag struct {
+	netip.Prefix
+}
+
+// Set is a flag.Value interface method to enable PrefixFlag as a command line flag.
+func (p *PrefixFlag) Set(prefix string) (err error) {
+	p.Prefix, err = netip.ParsePrefix(prefix)
+	return
+}
+
+// String is a flag.Value interface method to enable PrefixFlag as a command line flag.
+func (p *PrefixFlag) String() string {
+	if p == nil || !p.Prefix.IsValid() {
+		return ""
+	}
+	return p.Prefix.String()
+}
+
+// syntax formats the command line syntax of a PrefixFlag flag for usage.
+func (p *PrefixFlag) syntax(name string) string {
+	return "[-" + name + " address/bits]"
+}
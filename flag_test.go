@@ -0,0 +1,108 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMissingSkippedForInformationalFlags(t *testing.T) {
+	defer func(required []string, version versionFormat, completion string) {
+		Flags.required, Flags.version, Flags.completion = required, version, completion
+	}(Flags.required, Flags.version, Flags.completion)
+
+	Flags.required = []string{"not-defined"}
+	if err := missing(); err == nil {
+		t.Error("missing() reported no error for an unset required flag")
+	}
+
+	Flags.version = "text"
+	if err := missing(); err != nil {
+		t.Errorf("missing() with -version: %v", err)
+	}
+
+	Flags.version, Flags.completion = "", "bash"
+	if err := missing(); err != nil {
+		t.Errorf("missing() with -completion: %v", err)
+	}
+}
+
+func TestParseAppliesDeprecatedLoggingLevel(t *testing.T) {
+	defer func(level LogLevel) {
+		LoggingLevel = requestedLevel
+		SetLoggingLevel(level)
+	}(GetLoggingLevel())
+
+	LoggingLevel = LevelError
+	if err := parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if level := GetLoggingLevel(); level != LevelError {
+		t.Errorf("level %s after assigning LoggingLevel, want ERROR", level)
+	}
+}
+
+func TestParseKeepsLoggingLevel(t *testing.T) {
+	defer SetLoggingLevel(GetLoggingLevel())
+
+	SetLoggingLevel(LevelDebug)
+	if err := parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if level := GetLoggingLevel(); level != LevelDebug {
+		t.Errorf("level %s after parse without -loglevel, want DEBUG", level)
+	}
+
+	if err := parse([]string{"-loglevel", "warn"}); err != nil {
+		t.Fatal(err)
+	}
+	if level := GetLoggingLevel(); level != LevelWarn {
+		t.Errorf("level %s after parse with -loglevel warn, want WARN", level)
+	}
+}
+
+func TestStrictInterspersed(t *testing.T) {
+	defer func(strict, interspersed bool) {
+		Flags.Strict, Flags.Interspersed = strict, interspersed
+	}(Flags.Strict, Flags.Interspersed)
+	Flags.Strict = true
+
+	args := []string{"file.txt", "-cpuprofil"}
+	if err := parse(args); err != nil && strings.Contains(err.Error(), "unknown flag") {
+		t.Fatalf("parse(%q) without Interspersed treated an argument as a flag: %v", args, err)
+	}
+	Flags.Interspersed = true
+	err := parse(args)
+	if err == nil || !strings.Contains(err.Error(), "unknown flag -cpuprofil, did you mean -cpuprofile?") {
+		t.Errorf("parse(%q) error %v, want unknown flag -cpuprofil with a suggestion", args, err)
+	}
+}
+
+func TestCompletionOmitsHiddenFlags(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh"} {
+		script, err := Flags.Completion(shell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(script, "-version") {
+			t.Errorf("%s completion omits -version", shell)
+		}
+		if strings.Contains(script, "-completion") {
+			t.Errorf("%s completion offers the hidden -completion flag", shell)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	defer func(prefix string) { Flags.envPrefix = prefix }(Flags.envPrefix)
+
+	Flags.envPrefix = ""
+	if name := envName("record-stdin"); name != "RECORD_STDIN" {
+		t.Errorf("envName() without prefix = %s, want RECORD_STDIN", name)
+	}
+	Flags.EnvPrefix("gomon")
+	if name := envName("record-stdin"); name != "GOMON_RECORD_STDIN" {
+		t.Errorf("envName() = %s, want GOMON_RECORD_STDIN", name)
+	}
+}
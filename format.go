@@ -4,6 +4,7 @@ package gocore
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"strconv"
@@ -163,3 +164,110 @@ func CamelCase(s string) string {
 	}
 	return string(u)
 }
+
+// VisibleWidth measures the columns a string occupies on a terminal, skipping ANSI escape
+// sequences, counting zero width for combining marks and format characters, and counting
+// two columns for East Asian wide and fullwidth characters.
+func VisibleWidth(s string) int {
+	var width int
+	for i := 0; i < len(s); {
+		if s[i] == '\033' {
+			i += escapeLen(s[i:])
+			continue
+		}
+		c, n := utf8.DecodeRuneInString(s[i:])
+		i += n
+		switch {
+		case unicode.In(c, unicode.Mn, unicode.Me, unicode.Cf), unicode.IsControl(c):
+		case wide(c):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// escapeLen measures an ANSI escape sequence: a CSI sequence (ESC [ params final), an OSC
+// sequence (ESC ] text terminated by BEL or ESC \), or an escape and a single character.
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if 0x40 <= s[i] && s[i] <= 0x7E {
+				return i + 1
+			}
+		}
+	case ']':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\033' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	default:
+		return 2
+	}
+	return len(s)
+}
+
+// wide reports whether a character is East Asian wide or fullwidth, occupying two columns.
+func wide(c rune) bool {
+	return 0x1100 <= c && c <= 0x115F || // Hangul Jamo
+		0x2E80 <= c && c <= 0x303E || // CJK radicals, punctuation
+		0x3041 <= c && c <= 0x33FF || // Hiragana, Katakana, CJK compatibility
+		0x3400 <= c && c <= 0x4DBF || // CJK unified ideographs extension A
+		0x4E00 <= c && c <= 0x9FFF || // CJK unified ideographs
+		0xA000 <= c && c <= 0xA4CF || // Yi
+		0xAC00 <= c && c <= 0xD7A3 || // Hangul syllables
+		0xF900 <= c && c <= 0xFAFF || // CJK compatibility ideographs
+		0xFE30 <= c && c <= 0xFE4F || // CJK compatibility forms
+		0xFF00 <= c && c <= 0xFF60 || // fullwidth forms
+		0xFFE0 <= c && c <= 0xFFE6 ||
+		0x1F300 <= c && c <= 0x1F64F || // emoji
+		0x1F900 <= c && c <= 0x1F9FF ||
+		0x20000 <= c && c <= 0x3FFFD // CJK unified ideographs extensions
+}
+
+// ParseDuration interprets a bare number as a count of defaultUnit, a colon separated
+// [[HH:]MM:]SS clock form, or a Go duration string such as 1h30m.
+func ParseDuration(s string, defaultUnit time.Duration) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return duration(s, f*float64(defaultUnit))
+	}
+
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		var secs float64
+		for i, part := range parts {
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil || f < 0 || len(parts) > 3 || i < len(parts)-1 && f != math.Trunc(f) {
+				return 0, Error("ParseDuration", fmt.Errorf("invalid clock duration %q", s))
+			}
+			secs = secs*60 + f
+		}
+		return duration(s, secs*float64(time.Second))
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, Error("ParseDuration", err)
+	}
+	return d, nil
+}
+
+// duration converts a count of nanoseconds to a Duration, reporting an error if it is not a
+// finite number in the range of a Duration.
+func duration(s string, ns float64) (time.Duration, error) {
+	ns = math.Round(ns)
+	if math.IsNaN(ns) || ns < math.MinInt64 || ns >= math.MaxInt64 { // MaxInt64 rounds up as a float
+		return 0, Error("ParseDuration", fmt.Errorf("duration %q out of range", s))
+	}
+	return time.Duration(ns), nil
+}
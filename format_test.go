@@ -0,0 +1,65 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVisibleWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{"plain", "hello", 5},
+		{"empty", "", 0},
+		{"color", "\033[31mred\033[0m", 3},
+		{"bold color", "\033[1;38;5;208morange\033[m", 6},
+		{"hyperlink bel", "\033]8;;https://example.com\alink\033]8;;\a", 4},
+		{"hyperlink st", "\033]8;;https://example.com\033\\link\033]8;;\033\\", 4},
+		{"two character escape", "\033Mup", 2},
+		{"truncated escape", "ok\033[31", 2},
+		{"wide", "日本語", 6},
+		{"fullwidth", "ＡＢ", 4},
+		{"hangul", "한글", 4},
+		{"emoji", "😀", 2},
+		{"combining", "e\u0301", 1},
+		{"zero width joiner", "a\u200db", 2},
+		{"colored wide", "\033[32m中文\033[0m ok", 7},
+	}
+	for _, tt := range tests {
+		if got := VisibleWidth(tt.s); got != tt.want {
+			t.Errorf("%s: VisibleWidth(%q) = %d, want %d", tt.name, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Duration
+		err  bool
+	}{
+		{"90", 90 * time.Second, false},
+		{"1.5", 1500 * time.Millisecond, false},
+		{"01:30", 90 * time.Second, false},
+		{"1:00:00", time.Hour, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"inf", 0, true},
+		{"-Inf", 0, true},
+		{"NaN", 0, true},
+		{"1e30", 0, true},
+		{"inf:00", 0, true},
+		{"00:1e30", 0, true},
+		{"1:2:3:4", 0, true},
+		{"bogus", 0, true},
+	}
+	for _, tt := range tests {
+		d, err := ParseDuration(tt.s, time.Second)
+		if (err != nil) != tt.err || d != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v, error %t", tt.s, d, err, tt.want, tt.err)
+		}
+	}
+}
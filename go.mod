@@ -4,12 +4,12 @@ go 1.23.6
 
 require (
 	github.com/StackExchange/wmi v1.2.1
+	golang.org/x/mod v0.22.0
 	golang.org/x/sys v0.30.0
 	golang.org/x/tools v0.29.0
 )
 
 require (
 	github.com/go-ole/go-ole v1.2.5 // indirect
-	golang.org/x/mod v0.22.0 // indirect
 	golang.org/x/sync v0.10.0 // indirect
 )
@@ -3,15 +3,20 @@
 package gocore
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -20,6 +25,61 @@ type (
 	// LogLevel indexes the literal log levels.
 	LogLevel int
 
+	// LogFormat selects a built in encoding of log messages.
+	LogFormat int
+
+	// LogFormatter encodes a log message logged at a level and time as a single line,
+	// without the trailing newline.
+	LogFormatter interface {
+		Format(msg LogMessage, level LogLevel, t time.Time) []byte
+	}
+
+	// TextFormatter encodes log messages as human readable text, the default.
+	TextFormatter struct{}
+
+	// JSONFormatter encodes log messages as JSON objects.
+	JSONFormatter struct{}
+
+	// LogContextKey identifies a context value that ErrorContext adds to a message's details.
+	LogContextKey string
+
+	// logKey identifies the code location of log messages for rate limiting.
+	logKey struct {
+		source string
+		file   string
+		line   int
+	}
+
+	// logWindow counts the messages logged from a code location during an interval.
+	logWindow struct {
+		start      time.Time
+		count      int
+		suppressed int
+	}
+
+	// logLimiter limits the number of messages logged from each code location per interval.
+	logLimiter struct {
+		sync.Mutex
+		n       int
+		per     time.Duration
+		windows map[logKey]*logWindow
+		done    chan struct{}
+	}
+
+	// FirstError accumulates errors, e.g. of the steps of a shutdown, keeping the first to
+	// return and logging the rest as warnings so that none are silently dropped. Its zero value
+	// is ready for use, and it is safe for concurrent use.
+	FirstError struct {
+		sync.Mutex
+		err error
+	}
+
+	// levelWriter writes log messages to a destination that records their levels itself.
+	levelWriter interface {
+		write(LogMessage, LogLevel)
+		io.Closer
+	}
+
 	// LogMessage is custom logging error type.
 	LogMessage struct {
 		Source string
@@ -27,6 +87,7 @@ type (
 		File   string
 		Line   int
 		Detail map[string]string
+		values map[string]any // numeric values of details, encoded as numbers in JSON
 	}
 )
 
@@ -40,6 +101,19 @@ const (
 	LevelFatal
 )
 
+const (
+	// context keys of values that ErrorContext adds to a message's details.
+	TraceIDKey   LogContextKey = "trace_id"
+	SpanIDKey    LogContextKey = "span_id"
+	RequestIDKey LogContextKey = "request_id"
+)
+
+const (
+	// enum of log message encodings.
+	FormatText LogFormat = iota // default
+	FormatJSON
+)
+
 var (
 	// logLevels map logging level enums to log levels.
 	logLevels = map[LogLevel]string{
@@ -51,34 +125,251 @@ var (
 		LevelFatal: "FATAL",
 	}
 
-	// loggingLevel maps requested LOG_LEVEL to index.
-	LoggingLevel = func() LogLevel {
-		switch strings.ToUpper(os.Getenv("LOG_LEVEL")) {
-		case "TRACE":
-			return LevelTrace
-		case "DEBUG":
-			return LevelDebug
-		case "WARN":
-			return LevelWarn
-		case "ERROR":
-			return LevelError
-		case "FATAL":
-			return LevelFatal
-		}
-		return LevelInfo
+	// requestedLevel is the logging level requested by LOG_LEVEL.
+	requestedLevel, _ = ParseLogLevel(os.Getenv("LOG_LEVEL"))
+
+	// loggingLevel is the minimum level logged, initially the requested LOG_LEVEL.
+	loggingLevel = func() *atomic.Int64 {
+		l := &atomic.Int64{}
+		l.Store(int64(requestedLevel))
+		return l
+	}()
+
+	// LoggingLevel is the minimum level logged, initially the requested LOG_LEVEL.
+	//
+	// Deprecated: Use GetLoggingLevel and SetLoggingLevel, which are safe for concurrent use.
+	// A level assigned to LoggingLevel before Main takes effect when Main parses the command
+	// line. Main reads it only then, so treat it as read-only after startup. It does not
+	// reflect the level set by SetLoggingLevel.
+	LoggingLevel = requestedLevel
+
+	// highestLevel is the highest level of messages reported.
+	highestLevel = func() *atomic.Int64 {
+		l := &atomic.Int64{}
+		l.Store(int64(LevelTrace))
+		return l
 	}()
 
+	// logOutput is the encoder and destination of log messages.
+	logOutput = struct {
+		sync.Mutex
+		LogFormatter
+		io.Writer
+		syslog levelWriter                           // writes messages to syslog instead, if set by SetSyslog
+		export func(LogMessage, LogLevel, time.Time) // also exports messages, if set by SetOtelExporter
+	}{
+		LogFormatter: TextFormatter{},
+		Writer:       os.Stderr,
+	}
+
+	// logLimit is the rate limiter of log messages, nil if disabled.
+	logLimit atomic.Pointer[logLimiter]
+
 	// Log is the default log message formatter and writer.
 	Log = func(msg LogMessage, level LogLevel) {
-		if level >= LoggingLevel {
-			if msg.E == nil && level > LevelInfo {
-				level = LevelInfo
+		if msg.E == nil && level > LevelInfo {
+			level = LevelInfo
+		}
+		for {
+			if highest := highestLevel.Load(); int64(level) <= highest ||
+				highestLevel.CompareAndSwap(highest, int64(level)) {
+				break
+			}
+		}
+		if level >= GetLoggingLevel() {
+			if limiter := logLimit.Load(); limiter != nil && !limiter.allow(msg) {
+				return
 			}
-			log.Printf("%s %-5s %s", time.Now().Format(RFC3339Milli), logLevels[level], msg.Error())
+			write(msg, level)
 		}
 	}
 )
 
+// write formats a log message and writes it to the log output, and to the exporter if set.
+func write(msg LogMessage, level LogLevel) {
+	t := time.Now()
+	logOutput.Lock()
+	if logOutput.syslog != nil {
+		logOutput.syslog.write(msg, level)
+	} else {
+		line := append(logOutput.Format(msg, level, t), '\n')
+		logOutput.Write(line)
+	}
+	if logOutput.export != nil {
+		logOutput.export(msg, level, t)
+	}
+	logOutput.Unlock()
+}
+
+// Format encodes a log message as a timestamp, level, and the message's Error text.
+func (TextFormatter) Format(msg LogMessage, level LogLevel, t time.Time) []byte {
+	return fmt.Appendf(nil, "%s %-5s %s", t.Format(RFC3339Milli), logLevels[level], msg.Error())
+}
+
+// Format encodes a log message as a JSON object, with its details as a nested object.
+func (JSONFormatter) Format(msg LogMessage, level LogLevel, t time.Time) []byte {
+	return msg.json(level, t)
+}
+
+// SetLogRateLimit limits the messages logged from each code location (source, file, and line)
+// to n per interval, dropping the excess and logging a count of those suppressed as each
+// interval ends. A non-positive n or per disables rate limiting, the default.
+func SetLogRateLimit(n int, per time.Duration) {
+	var limiter *logLimiter
+	if n > 0 && per > 0 {
+		limiter = &logLimiter{
+			n:       n,
+			per:     per,
+			windows: map[logKey]*logWindow{},
+			done:    make(chan struct{}),
+		}
+		go limiter.sweep()
+	}
+	if prev := logLimit.Swap(limiter); prev != nil {
+		close(prev.done)
+	}
+}
+
+// allow reports whether a message is within its code location's budget for the current interval.
+func (limiter *logLimiter) allow(msg LogMessage) bool {
+	key := logKey{source: msg.Source, file: msg.File, line: msg.Line}
+	now := time.Now()
+	limiter.Lock()
+	defer limiter.Unlock()
+	w, ok := limiter.windows[key]
+	if !ok {
+		w = &logWindow{start: now}
+		limiter.windows[key] = w
+	}
+	if w.count++; w.count > limiter.n {
+		w.suppressed++
+		return false
+	}
+	return true
+}
+
+// sweep periodically ends expired intervals, reporting the messages suppressed during each.
+func (limiter *logLimiter) sweep() {
+	ticker := time.NewTicker(limiter.per)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-limiter.done:
+			return
+		case now := <-ticker.C:
+			var msgs []LogMessage
+			limiter.Lock()
+			for key, w := range limiter.windows {
+				if now.Sub(w.start) < limiter.per {
+					continue
+				}
+				if w.suppressed > 0 {
+					msgs = append(msgs, LogMessage{
+						Source: key.source,
+						File:   key.file,
+						Line:   key.line,
+						Detail: map[string]string{
+							"suppressed": strconv.Itoa(w.suppressed),
+							"interval":   limiter.per.String(),
+						},
+					})
+				}
+				delete(limiter.windows, key)
+			}
+			limiter.Unlock()
+			for _, msg := range msgs {
+				write(msg, LevelWarn)
+			}
+		}
+	}
+}
+
+// SetLogOutput redirects formatted log messages to a writer, os.Stderr by default. If log
+// messages are routed to syslog by SetSyslog, the connection is closed and routing stops.
+func SetLogOutput(w io.Writer) {
+	logOutput.Lock()
+	logOutput.Writer = w
+	if logOutput.syslog != nil {
+		logOutput.syslog.Close()
+		logOutput.syslog = nil
+	}
+	logOutput.Unlock()
+}
+
+// SetLogDestination directs log messages to standard error (the default) or standard output.
+func SetLogDestination(d Destination) {
+	SetLogOutput(d.File())
+}
+
+// ParseLogLevel converts a level name, in any case, to its LogLevel. An empty or
+// unrecognized name is reported as LevelInfo, with an error for the latter.
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch strings.ToUpper(name) {
+	case "TRACE":
+		return LevelTrace, nil
+	case "DEBUG":
+		return LevelDebug, nil
+	case "", "INFO":
+		return LevelInfo, nil
+	case "WARN":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	case "FATAL":
+		return LevelFatal, nil
+	}
+	return LevelInfo, Error("ParseLogLevel", fmt.Errorf("invalid log level %q", name))
+}
+
+// String returns the name of a logging level.
+func (level LogLevel) String() string {
+	return logLevels[level]
+}
+
+// Set is a flag.Value interface method to enable LogLevel as a command line flag.
+func (level *LogLevel) Set(name string) error {
+	l, err := ParseLogLevel(name)
+	if err != nil {
+		return errors.New("valid levels are trace, debug, info, warn, error, fatal")
+	}
+	*level = l
+	return nil
+}
+
+// GetLoggingLevel reports the minimum level of messages that are logged.
+func GetLoggingLevel() LogLevel {
+	return LogLevel(loggingLevel.Load())
+}
+
+// SetLoggingLevel changes the minimum level of messages that are logged.
+func SetLoggingLevel(level LogLevel) {
+	loggingLevel.Store(int64(level))
+}
+
+// HighestLevel reports the highest level of messages reported during this invocation, whether
+// or not they were logged, e.g. to determine whether the command ran cleanly.
+func HighestLevel() LogLevel {
+	return LogLevel(highestLevel.Load())
+}
+
+// SetLogFormat selects a built in encoding of log messages, text by default.
+func SetLogFormat(format LogFormat) {
+	switch format {
+	case FormatJSON:
+		SetLogFormatter(JSONFormatter{})
+	default:
+		SetLogFormatter(TextFormatter{})
+	}
+}
+
+// SetLogFormatter selects a custom encoding of log messages for the log output. It does not
+// apply to messages routed to syslog, which records each message's Error text.
+func SetLogFormatter(formatter LogFormatter) {
+	logOutput.Lock()
+	logOutput.LogFormatter = formatter
+	logOutput.Unlock()
+}
+
 // Unsupported reports that a specific OS does not support a function
 func Unsupported() error {
 	return Error("Unsupported", errors.New(runtime.GOOS))
@@ -115,6 +406,40 @@ func (msg LogMessage) Error() string {
 	)
 }
 
+// json encodes a log message as a single line JSON object.
+func (msg LogMessage) json(level LogLevel, t time.Time) []byte {
+	var e string
+	if msg.E != nil {
+		e = msg.E.Error()
+	}
+	detail := map[string]any{}
+	for key, val := range msg.Detail {
+		if v, ok := msg.values[key]; ok {
+			detail[key] = v
+		} else if val != "" {
+			detail[key] = val
+		}
+	}
+	b, _ := json.Marshal(struct {
+		Time   string         `json:"time"`
+		Level  string         `json:"level"`
+		Source string         `json:"source"`
+		Err    string         `json:"err,omitempty"`
+		File   string         `json:"file"`
+		Line   int            `json:"line"`
+		Detail map[string]any `json:"detail,omitempty"`
+	}{
+		Time:   t.Format(RFC3339Milli),
+		Level:  logLevels[level],
+		Source: msg.Source,
+		Err:    e,
+		File:   msg.File,
+		Line:   msg.Line,
+		Detail: detail,
+	})
+	return b
+}
+
 // Unwrap method to comply with error interface.
 func (msg LogMessage) Unwrap() error {
 	return msg.E
@@ -123,12 +448,79 @@ func (msg LogMessage) Unwrap() error {
 // Error records the function source, error message, code location, and any
 // details of initial error, preserving the initial error for percolation.
 func Error(source string, err error, details ...map[string]string) LogMessage {
+	return message(source, err, details...)
+}
+
+// ErrorContext acts like Error, additionally adding to the details the values of the
+// context keys TraceIDKey, SpanIDKey, and RequestIDKey that are present in ctx. This
+// correlates the messages logged while handling a request.
+func ErrorContext(ctx context.Context, source string, err error, details ...map[string]string) LogMessage {
+	detail := map[string]string{}
+	for _, key := range []LogContextKey{TraceIDKey, SpanIDKey, RequestIDKey} {
+		if val := ctx.Value(key); val != nil {
+			detail[string(key)] = fmt.Sprint(val)
+		}
+	}
+	return message(source, err, append([]map[string]string{detail}, details...)...)
+}
+
+// Timed times an operation, logging its elapsed time at DEBUG level, or at WARN level if it
+// exceeds the threshold. If the operation fails, Timed returns its error as a LogMessage.
+func Timed(source string, threshold time.Duration, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+
+	e := err
+	if e == nil && elapsed > threshold {
+		e = errors.New("operation exceeded threshold")
+	}
+	msg := message(source, e, map[string]string{
+		"elapsed":   elapsed.String(),
+		"threshold": threshold.String(),
+	})
+	if elapsed > threshold {
+		msg.Warn()
+	} else {
+		msg.Debug()
+	}
+
+	if err != nil {
+		return msg
+	}
+	return nil
+}
+
+// Record keeps the first error recorded, and logs any later error as a warning.
+func (fe *FirstError) Record(source string, err error) {
+	if err == nil {
+		return
+	}
+	msg := message(source, err)
+	fe.Lock()
+	defer fe.Unlock()
+	if fe.err == nil {
+		fe.err = msg
+		return
+	}
+	msg.Warn()
+}
+
+// Err returns the first error recorded, or nil if none.
+func (fe *FirstError) Err() error {
+	fe.Lock()
+	defer fe.Unlock()
+	return fe.err
+}
+
+// message builds a log message for Error, ErrorContext, Timed, and Record, locating their caller.
+func message(source string, err error, details ...map[string]string) LogMessage {
 	e := LogMessage{}
 	if errors.As(err, &e) {
 		return e // percolate original Err
 	}
 
-	_, file, line, _ := runtime.Caller(1)
+	_, file, line, _ := runtime.Caller(2)
 	file = filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
 
 	detail := map[string]string{}
@@ -153,6 +545,36 @@ func Error(source string, err error, details ...map[string]string) LogMessage {
 	}
 }
 
+// With returns a copy of the message with a detail added, formatting the value with fmt.Sprint.
+func (msg LogMessage) With(key string, value any) LogMessage {
+	detail := make(map[string]string, len(msg.Detail)+1)
+	maps.Copy(detail, msg.Detail)
+	detail[key] = fmt.Sprint(value)
+	msg.Detail = detail
+	return msg
+}
+
+// WithDuration returns a copy of the message with a duration detail added, logged as text in
+// the duration's String format and as JSON in seconds, so that log aggregators may sum it.
+func (msg LogMessage) WithDuration(key string, d time.Duration) LogMessage {
+	return msg.withValue(key, d.String(), d.Seconds())
+}
+
+// WithBytes returns a copy of the message with a byte count detail added, logged as a number.
+func (msg LogMessage) WithBytes(key string, n int64) LogMessage {
+	return msg.withValue(key, strconv.FormatInt(n, 10), n)
+}
+
+// withValue returns a copy of the message with a detail added and its numeric value recorded.
+func (msg LogMessage) withValue(key, text string, value any) LogMessage {
+	msg = msg.With(key, text)
+	values := make(map[string]any, len(msg.values)+1)
+	maps.Copy(values, msg.values)
+	values[key] = value
+	msg.values = values
+	return msg
+}
+
 // Trace log trace message.
 func (msg LogMessage) Trace() {
 	Log(msg, LevelTrace)
@@ -0,0 +1,163 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+//go:build otel
+
+package gocore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type (
+	// otelValue is an OTLP AnyValue.
+	otelValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+	}
+
+	// otelAttribute is an OTLP KeyValue.
+	otelAttribute struct {
+		Key   string    `json:"key"`
+		Value otelValue `json:"value"`
+	}
+
+	// otelRecord is an OTLP LogRecord.
+	otelRecord struct {
+		TimeUnixNano   string          `json:"timeUnixNano"`
+		SeverityNumber int             `json:"severityNumber"`
+		SeverityText   string          `json:"severityText"`
+		Body           otelValue       `json:"body"`
+		Attributes     []otelAttribute `json:"attributes,omitempty"`
+	}
+)
+
+const (
+	// otelSource is the source of messages reporting failures to export log records.
+	otelSource = "otel export"
+)
+
+var (
+	// otelClient posts log records, bounding the time a collector may stall the exporter.
+	otelClient = &http.Client{Timeout: 10 * time.Second}
+
+	// otelSeverity maps logging levels to OTLP severity numbers.
+	otelSeverity = map[LogLevel]int{
+		LevelTrace: 1,
+		LevelDebug: 5,
+		LevelInfo:  9,
+		LevelWarn:  13,
+		LevelError: 17,
+		LevelFatal: 21,
+	}
+)
+
+// SetOtelExporter routes log messages, in addition to the log output, to an OpenTelemetry
+// collector as OTLP log records. The records are batched and posted with JSON encoding over
+// HTTP to endpoint (e.g. http://localhost:4318/v1/logs) until ctx is cancelled. Messages are
+// exported only if logged, i.e. at or above the logging level and within the rate limit.
+// The OTLP/gRPC transport is not supported, to keep gocore dependency free.
+// Build with -tags otel to include the exporter.
+func SetOtelExporter(ctx context.Context, endpoint, service string) {
+	records := make(chan otelRecord, 1024)
+	logOutput.Lock()
+	logOutput.export = func(msg LogMessage, level LogLevel, t time.Time) {
+		if msg.Source == otelSource {
+			return // do not export failures to export
+		}
+		select {
+		case records <- msg.otel(level, t):
+		default: // drop rather than block the caller if the collector falls behind
+		}
+	}
+	logOutput.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		var batch []otelRecord
+		for {
+			select {
+			case <-ctx.Done():
+				for len(records) > 0 {
+					batch = append(batch, <-records)
+				}
+				otelExport(endpoint, service, batch)
+				return
+			case record := <-records:
+				if batch = append(batch, record); len(batch) >= 100 {
+					otelExport(endpoint, service, batch)
+					batch = nil
+				}
+			case <-ticker.C:
+				otelExport(endpoint, service, batch)
+				batch = nil
+			}
+		}
+	}()
+}
+
+// otel maps a log message to an OTLP log record.
+func (msg LogMessage) otel(level LogLevel, t time.Time) otelRecord {
+	body := msg.Source
+	if msg.E != nil {
+		body += ": " + msg.E.Error()
+	}
+	line := strconv.Itoa(msg.Line)
+	attrs := []otelAttribute{
+		{Key: "code.filepath", Value: otelValue{StringValue: &msg.File}},
+		{Key: "code.lineno", Value: otelValue{IntValue: &line}},
+	}
+	for key, val := range msg.Detail {
+		if val != "" {
+			attrs = append(attrs, otelAttribute{Key: key, Value: otelValue{StringValue: &val}})
+		}
+	}
+	return otelRecord{
+		TimeUnixNano:   strconv.FormatInt(t.UnixNano(), 10),
+		SeverityNumber: otelSeverity[level],
+		SeverityText:   logLevels[level],
+		Body:           otelValue{StringValue: &body},
+		Attributes:     attrs,
+	}
+}
+
+// otelExport posts a batch of log records to the collector, reporting failures to the log
+// output only.
+func otelExport(endpoint, service string, batch []otelRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	b, _ := json.Marshal(map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otelAttribute{
+					{Key: "service.name", Value: otelValue{StringValue: &service}},
+				},
+			},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]string{"name": "github.com/zosmac/gocore"},
+				"logRecords": batch,
+			}},
+		}},
+	})
+
+	resp, err := otelClient.Post(endpoint, "application/json", bytes.NewReader(b))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			err = errors.New(resp.Status)
+		}
+	}
+	if err != nil {
+		Error(otelSource, err, map[string]string{
+			"endpoint": endpoint,
+			"records":  strconv.Itoa(len(batch)),
+		}).Warn()
+	}
+}
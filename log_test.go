@@ -0,0 +1,30 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSetLoggingLevelConcurrent(t *testing.T) {
+	defer SetLoggingLevel(GetLoggingLevel())
+
+	var wg sync.WaitGroup
+	for _, level := range []LogLevel{LevelDebug, LevelWarn, LevelError} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				SetLoggingLevel(level)
+				GetLoggingLevel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	SetLoggingLevel(LevelWarn)
+	if level := GetLoggingLevel(); level != LevelWarn {
+		t.Errorf("GetLoggingLevel() = %s, want WARN", level)
+	}
+}
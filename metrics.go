@@ -0,0 +1,172 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// Counter is a metric whose value only increases, such as a count of events processed.
+	Counter struct {
+		bits atomic.Uint64
+	}
+
+	// Gauge is a metric whose value may increase or decrease, such as a count of open files.
+	Gauge struct {
+		bits atomic.Uint64
+	}
+)
+
+var (
+	// metrics is the registry of counters and gauges by name.
+	metrics = struct {
+		sync.RWMutex
+		counters map[string]*Counter
+		gauges   map[string]*Gauge
+	}{
+		counters: map[string]*Counter{},
+		gauges:   map[string]*Gauge{},
+	}
+)
+
+// NewCounter registers a counter, or returns the counter already registered with the name.
+func NewCounter(name string) *Counter {
+	metrics.Lock()
+	defer metrics.Unlock()
+	c, ok := metrics.counters[name]
+	if !ok {
+		c = &Counter{}
+		metrics.counters[name] = c
+	}
+	return c
+}
+
+// NewGauge registers a gauge, or returns the gauge already registered with the name.
+func NewGauge(name string) *Gauge {
+	metrics.Lock()
+	defer metrics.Unlock()
+	g, ok := metrics.gauges[name]
+	if !ok {
+		g = &Gauge{}
+		metrics.gauges[name] = g
+	}
+	return g
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increases the counter by v, ignoring a negative v.
+func (c *Counter) Add(v float64) {
+	if v > 0 {
+		add(&c.bits, v)
+	}
+}
+
+// Value returns the counter's value.
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Inc increments the gauge by 1.
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec decrements the gauge by 1.
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Add adds v to the gauge.
+func (g *Gauge) Add(v float64) {
+	add(&g.bits, v)
+}
+
+// Value returns the gauge's value.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// add atomically adds to a float64 stored as its bits.
+func add(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Snapshot returns the current values of all registered counters and gauges by name.
+func Snapshot() map[string]float64 {
+	metrics.RLock()
+	defer metrics.RUnlock()
+	m := make(map[string]float64, len(metrics.counters)+len(metrics.gauges))
+	for name, c := range metrics.counters {
+		m[name] = c.Value()
+	}
+	for name, g := range metrics.gauges {
+		m[name] = g.Value()
+	}
+	return m
+}
+
+// MetricsHandler serves the registered counters and gauges in the Prometheus text exposition format,
+// e.g. for mux.Handle("/metrics", gocore.MetricsHandler()).
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		type metric struct {
+			name  string
+			kind  string
+			value float64
+		}
+		var ms []metric
+		metrics.RLock()
+		for name, c := range metrics.counters {
+			ms = append(ms, metric{promName(name), "counter", c.Value()})
+		}
+		for name, g := range metrics.gauges {
+			ms = append(ms, metric{promName(name), "gauge", g.Value()})
+		}
+		metrics.RUnlock()
+		slices.SortFunc(ms, func(a, b metric) int {
+			return cmp.Compare(a.name, b.name)
+		})
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, m := range ms {
+			fmt.Fprintf(w, "# TYPE %[1]s %[2]s\n%[1]s %[3]s\n",
+				m.name,
+				m.kind,
+				strconv.FormatFloat(m.value, 'g', -1, 64),
+			)
+		}
+	})
+}
+
+// promName replaces the characters of a metric name that Prometheus does not permit.
+func promName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
@@ -4,52 +4,325 @@ package gocore
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime"
-	"runtime/pprof"
+	rpprof "runtime/pprof"
+	"runtime/trace"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// profile turns on CPU performance or Memory usage profiling of command.
-// Profiling can also be enabled via the /debug/pprof endpoint.
+var (
+	// profiles records the paths of profiles written and the callbacks to notify of each.
+	profiles = struct {
+		sync.Mutex
+		paths   []string
+		notify  []func(string)
+		pending sync.WaitGroup // profiles yet to be written
+	}{}
+
+	// profileManifest lists the profiles that invocations of the command created, each with the
+	// process id of the invocation, so that -cleanup-profiles removes only those.
+	profileManifest = filepath.Join(os.TempDir(), "gocore_profiles_"+filepath.Base(Executable))
+)
+
+// ProfilePaths returns the paths of the profiles written by this invocation.
+func ProfilePaths() []string {
+	profiles.Lock()
+	defer profiles.Unlock()
+	return slices.Clone(profiles.paths)
+}
+
+// OnProfile registers a callback that is invoked with the path of each profile written,
+// e.g. to upload profiles to storage.
+func OnProfile(fn func(path string)) {
+	profiles.Lock()
+	profiles.notify = append(profiles.notify, fn)
+	profiles.Unlock()
+}
+
+// SetProfileDir sets the directory where profiles are written, as does the -profiledir flag.
+// By default, the CPU and runtime profiles are written to the temporary directory and the
+// memory profile to the current directory.
+func SetProfileDir(dir string) {
+	Flags.profiledir = dir
+}
+
+// profile turns on CPU performance or Memory usage profiling, or execution tracing, of command.
+// Profiling can also be enabled via the /debug/pprof endpoint of EnablePprofServer.
 func profile(ctx context.Context) {
+	if Flags.cleanupProfiles {
+		cleanup()
+	}
+
+	if Flags.cpuprofileRate != 0 {
+		if !Flags.cpuprofile {
+			Error("cpuprofile-rate", errors.New("ignored without -cpuprofile")).Warn()
+		} else if Flags.cpuprofileRate < 0 || Flags.cpuprofileRate > 1000000 {
+			Error("cpuprofile-rate", errors.New("rate must be between 1 and 1000000 hz"), map[string]string{
+				"rate": strconv.Itoa(Flags.cpuprofileRate),
+			}).Warn()
+			Flags.cpuprofileRate = 0
+		}
+	}
+
 	if Flags.cpuprofile {
-		if f, err := os.CreateTemp("", "pprof_"); err != nil {
+		if f, err := create("", "pprof_"); err != nil {
 			Error("cpuprofile", err).Err()
 		} else {
+			profiles.pending.Add(1)
 			go func() {
-				pprof.StartCPUProfile(f)
+				defer profiles.pending.Done()
+				if err := startCPUProfile(f, Flags.cpuprofileRate); err != nil {
+					Error("cpuprofile", err).Warn()
+					f.Close()
+					return
+				}
 				<-ctx.Done()
-				pprof.StopCPUProfile()
-				cmd, _ := os.Executable()
-				fmt.Fprintf(os.Stderr,
-					"CPU profile written to %[1]q.\nUse the following command to evaluate:\n"+
-						"\033[1;31mgo tool pprof -web %[2]s %[1]s\033[0m\n",
-					f.Name(),
-					cmd,
-				)
-				f.Close()
+				rpprof.StopCPUProfile()
+				written("CPU profile", "pprof", f)
 			}()
 		}
 	}
 
 	if Flags.memprofile {
-		if f, err := os.CreateTemp(".", "mprof_"); err != nil {
+		if f, err := create(".", "mprof_"); err != nil {
 			Error("memprofile", err).Err()
 		} else {
+			profiles.pending.Add(1)
 			go func() {
+				defer profiles.pending.Done()
 				<-ctx.Done()
 				runtime.GC()
-				pprof.WriteHeapProfile(f)
-				cmd, _ := os.Executable()
-				fmt.Fprintf(os.Stderr,
-					"Memory profile written to %[1]q.\nUse the following command to evaluate:\n"+
-						"\033[1;31mgo tool pprof -web %[2]s %[1]s\033[0m\n",
-					f.Name(),
-					cmd,
-				)
-				f.Close()
+				rpprof.WriteHeapProfile(f)
+				written("Memory profile", "pprof", f)
 			}()
 		}
 	}
+
+	if Flags.blockprofile {
+		runtime.SetBlockProfileRate(1)
+		lookup(ctx, "block", "bprof_", "Block profile")
+	}
+
+	if Flags.mutexprofile {
+		runtime.SetMutexProfileFraction(1)
+		lookup(ctx, "mutex", "lprof_", "Mutex profile")
+	}
+
+	if Flags.goroutineprofile {
+		lookup(ctx, "goroutine", "gprof_", "Goroutine profile")
+	}
+
+	if Flags.trace {
+		if f, err := create("", "trace_"); err != nil {
+			Error("trace", err).Err()
+		} else if err := trace.Start(f); err != nil {
+			f.Close()
+			Error("trace", err).Err()
+		} else {
+			profiles.pending.Add(1)
+			go func() {
+				defer profiles.pending.Done()
+				<-ctx.Done()
+				trace.Stop()
+				written("Execution trace", "trace", f)
+			}()
+		}
+	}
+}
+
+// startCPUProfile starts CPU profiling at a rate, or at the default 100hz if rate is 0. The rate
+// must be set before StartCPUProfile, which cannot change it once set, so the runtime's report
+// that it ignores StartCPUProfile's attempt to set 100hz is suppressed.
+func startCPUProfile(w io.Writer, rate int) error {
+	if rate == 0 {
+		return rpprof.StartCPUProfile(w)
+	}
+	var err error
+	quietStderr(func() {
+		runtime.SetCPUProfileRate(rate)
+		err = rpprof.StartCPUProfile(w)
+	})
+	if err != nil { // e.g. profiling began through the /debug/pprof/profile endpoint
+		return fmt.Errorf("rate %d hz ignored, as it is set after profiling began: %w", rate, err)
+	}
+	return nil
+}
+
+// lookup writes a runtime profile by name when the context is cancelled.
+func lookup(ctx context.Context, name, prefix, kind string) {
+	if f, err := create("", prefix); err != nil {
+		Error(name+"profile", err).Err()
+	} else {
+		profiles.pending.Add(1)
+		go func() {
+			defer profiles.pending.Done()
+			<-ctx.Done()
+			rpprof.Lookup(name).WriteTo(f, 0)
+			written(kind, "pprof", f)
+		}()
+	}
+}
+
+// EnablePprofServer serves the /debug/pprof endpoints of net/http/pprof at an address until
+// the context of Main is cancelled. It returns an error if it cannot listen on the address.
+func EnablePprofServer(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return Error("pprof", err, map[string]string{
+			"address": addr,
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{Handler: mux}
+
+	go func() {
+		<-mainDone
+		srv.Shutdown(context.Background())
+	}()
+
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			Error("pprof", err, map[string]string{
+				"address": l.Addr().String(),
+			}).Err()
+		}
+	}()
+
+	Error("pprof", nil, map[string]string{
+		"address": l.Addr().String(),
+	}).Info()
+
+	return nil
+}
+
+// cleanup removes the profiles that previous invocations of the command recorded in the profile
+// manifest, keeping those of invocations still running. Files that other commands or tools write
+// to the profile directories are left alone, even if named like profiles.
+func cleanup() {
+	b, err := os.ReadFile(profileManifest)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			Error("cleanup-profiles", err, map[string]string{
+				"manifest": profileManifest,
+			}).Warn()
+		}
+		return
+	}
+
+	pids, err := Processes()
+	if err != nil {
+		Error("cleanup-profiles", err).Warn()
+		return // cannot tell which invocations are still writing their profiles
+	}
+	var kept []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		p, path, ok := strings.Cut(line, " ")
+		pid, err := strconv.Atoi(p)
+		if !ok || err != nil || !profileName(filepath.Base(path)) {
+			continue
+		}
+		if slices.Contains(pids, pid) { // invocation still running
+			kept = append(kept, line+"\n")
+			continue
+		}
+		err = os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		Error("cleanup-profiles", err, map[string]string{
+			"path": path,
+		}).Info()
+	}
+
+	if err := os.WriteFile(profileManifest, []byte(strings.Join(kept, "")), 0o600); err != nil {
+		Error("cleanup-profiles", err, map[string]string{
+			"manifest": profileManifest,
+		}).Warn()
+	}
+}
+
+// record adds the path of a profile that this invocation created to the profile manifest.
+func record(path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	f, err := os.OpenFile(profileManifest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err == nil {
+		_, err = fmt.Fprintf(f, "%d %s\n", os.Getpid(), path) // a single append, so not interleaved
+		f.Close()
+	}
+	if err != nil {
+		Error("profile manifest", err, map[string]string{
+			"manifest": profileManifest,
+		}).Warn()
+	}
+}
+
+// profileName reports whether a file name is that of a profile.
+func profileName(name string) bool {
+	for _, prefix := range []string{"pprof_", "mprof_", "bprof_", "lprof_", "gprof_", "trace_"} {
+		if digits, ok := strings.CutPrefix(name, prefix); ok && digits != "" &&
+			strings.Trim(digits, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// create opens a new profile file in the profile directory, or if not set, in the default
+// directory, and records it in the profile manifest for -cleanup-profiles.
+func create(dir, prefix string) (*os.File, error) {
+	if Flags.profiledir != "" {
+		dir = Flags.profiledir
+	}
+	f, err := os.CreateTemp(dir, prefix)
+	if err == nil {
+		record(f.Name())
+	}
+	return f, err
+}
+
+// written closes a profile file, reports how to evaluate it with the go tool, and records its path.
+func written(kind, tool string, f *os.File) {
+	f.Close()
+	hint := "go tool trace " + f.Name()
+	if tool == "pprof" {
+		cmd, _ := os.Executable()
+		hint = "go tool pprof -web " + cmd + " " + f.Name()
+	}
+	if ColorEnabled() {
+		hint = "\033[1;31m" + hint + "\033[0m"
+	}
+	fmt.Fprintf(console(),
+		"%s written to %q.\nUse the following command to evaluate:\n%s\n",
+		kind,
+		f.Name(),
+		hint,
+	)
+
+	profiles.Lock()
+	profiles.paths = append(profiles.paths, f.Name())
+	notify := slices.Clone(profiles.notify)
+	profiles.Unlock()
+	for _, fn := range notify {
+		fn(f.Name())
+	}
 }
@@ -0,0 +1,99 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	rpprof "runtime/pprof"
+	"strings"
+	"testing"
+)
+
+func TestCleanupRemovesOnlyRecordedProfiles(t *testing.T) {
+	dir := t.TempDir()
+	defer func(manifest string) { profileManifest = manifest }(profileManifest)
+	profileManifest = filepath.Join(dir, "manifest")
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	exited := cmd.Process.Pid
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	for _, name := range []string{"pprof_1", "mprof_2", "pprof_3", "pprof_4"} {
+		if err := os.WriteFile(path(name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := fmt.Sprintf("%[1]d %[3]s\n%[1]d %[4]s\n%[2]d %[5]s\n%[1]d %[6]s\n",
+		exited, os.Getpid(), path("pprof_1"), path("mprof_2"), path("pprof_3"), path("notes.txt"))
+	if err := os.WriteFile(profileManifest, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup()
+
+	for name, want := range map[string]bool{
+		"pprof_1": false, // recorded by an exited invocation
+		"mprof_2": false,
+		"pprof_3": true, // recorded by a running invocation
+		"pprof_4": true, // not recorded
+	} {
+		if _, err := os.Stat(path(name)); (err == nil) != want {
+			t.Errorf("%s exists %t after cleanup, want %t", name, err == nil, want)
+		}
+	}
+	b, _ := os.ReadFile(profileManifest)
+	if want := fmt.Sprintf("%d %s\n", os.Getpid(), path("pprof_3")); string(b) != want {
+		t.Errorf("manifest after cleanup %q, want %q", b, want)
+	}
+}
+
+func TestCreateRecordsProfile(t *testing.T) {
+	dir := t.TempDir()
+	defer func(manifest string) { profileManifest = manifest }(profileManifest)
+	profileManifest = filepath.Join(dir, "manifest")
+
+	f, err := create(dir, "pprof_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	b, _ := os.ReadFile(profileManifest)
+	if want := fmt.Sprintf("%d %s\n", os.Getpid(), f.Name()); string(b) != want {
+		t.Errorf("manifest %q, want %q", b, want)
+	}
+	if !strings.HasPrefix(filepath.Base(f.Name()), "pprof_") {
+		t.Errorf("profile %s", f.Name())
+	}
+}
+
+func TestStartCPUProfileRate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := startCPUProfile(&buf, 500); err != nil {
+		t.Fatal(err)
+	}
+	rpprof.StopCPUProfile()
+	if buf.Len() == 0 {
+		t.Error("no CPU profile written")
+	}
+}
+
+func TestStartCPUProfileRateAfterProfilingBegan(t *testing.T) {
+	if err := rpprof.StartCPUProfile(io.Discard); err != nil {
+		t.Skip(err)
+	}
+	defer rpprof.StopCPUProfile()
+
+	if err := startCPUProfile(io.Discard, 500); err == nil {
+		t.Error("startCPUProfile() reported no error for a rate set after profiling began")
+	} else if !strings.Contains(err.Error(), "after profiling began") {
+		t.Errorf("startCPUProfile() error %v", err)
+	}
+}
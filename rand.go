@@ -0,0 +1,46 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+type (
+	// lockedSource serializes access to a random source so that Rand is safe for concurrent use.
+	lockedSource struct {
+		sync.Mutex
+		src rand.Source
+	}
+)
+
+var (
+	// randSource is the source of Rand, randomly seeded unless set by SetRandSource.
+	randSource = &lockedSource{
+		src: rand.NewPCG(rand.Uint64(), rand.Uint64()),
+	}
+
+	// Rand is the source of randomness of gocore, such as for jitter. It is safe for concurrent use.
+	Rand = rand.New(randSource)
+)
+
+// Uint64 returns the next value of the source.
+func (s *lockedSource) Uint64() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.src.Uint64()
+}
+
+// SetRandSource replaces the source of Rand, e.g. with a fixed seed source for reproducible tests.
+func SetRandSource(src rand.Source) {
+	randSource.Lock()
+	randSource.src = src
+	randSource.Unlock()
+}
+
+// Jitter randomly adjusts a duration by up to ± fraction of its value, e.g. to spread retries.
+func Jitter(d time.Duration, fraction float64) time.Duration {
+	return d + time.Duration(float64(d)*fraction*(2*Rand.Float64()-1))
+}
@@ -3,12 +3,18 @@
 package gocore
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"net"
 	"net/netip"
+	"os"
 	"os/user"
 	"runtime"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/tools/go/packages"
@@ -21,9 +27,23 @@ type (
 	// gname is key to cached group name.
 	gname int
 
+	// uidname is key to cached user id.
+	uidname string
+
+	// gidname is key to cached group id.
+	gidname string
+
 	// hname is key to cached host name.
 	hname string
 
+	// SocketInfo describes an internet socket held open by a process.
+	SocketInfo struct {
+		Protocol string // tcp, tcp6, udp, or udp6
+		Local    netip.AddrPort
+		Remote   netip.AddrPort
+		State    string // TCP state, such as LISTEN or ESTABLISHED
+	}
+
 	// moddir is key to cached go module information.
 	moddir string
 
@@ -39,19 +59,71 @@ var (
 	// DarkAppearance indicates whether system appearance is "dark" or "light"
 	DarkAppearance bool
 
+	// AppearanceEvents publishes changes to the system appearance, true if changed to "dark".
+	AppearanceEvents EventBus[bool]
+
 	// unames is the cache of user names.
-	unames = newCache(username)
+	unames = NewCache(username, 0)
 
 	// gnames is the cache of group names.
-	gnames = newCache(groupname)
+	gnames = NewCache(groupname, 0)
+
+	// uids is the cache of user ids by name, -1 if not found.
+	uids = NewCache(userid, 0)
+
+	// gids is the cache of group ids by name, -1 if not found.
+	gids = NewCache(groupid, 0)
+
+	// primeUsers loads the local users and groups into the caches once.
+	primeUsers sync.Once
 
 	// hnames is the cache of host names.
-	hnames = newCache(hostname)
+	hnames = NewCache(hostname, 0)
+
+	// hostLookups configures the network lookups of host names: how long to wait for one,
+	// and how long to wait after one fails before looking up the address again.
+	hostLookups = struct {
+		sync.Mutex
+		timeout time.Duration
+		retry   time.Duration
+	}{
+		timeout: 5 * time.Second,
+		retry:   time.Minute,
+	}
 
 	// mnames is the cache of go module information.
-	mnames = newCache(modinfo)
+	mnames = NewCache(modinfo, 0)
+
+	// bootTime caches the system boot time, determined on first use. If it cannot be
+	// determined, the zero time is cached so that the failure is not retried and logged again.
+	bootTime = struct {
+		sync.Mutex
+		time.Time
+		determined bool
+	}{}
 )
 
+// Boottime returns the system boot time, or the zero time if it cannot be determined.
+// Boottime was formerly a variable; code that read gocore.Boottime now calls gocore.Boottime().
+func Boottime() time.Time {
+	bootTime.Lock()
+	defer bootTime.Unlock()
+	if !bootTime.determined {
+		bootTime.Time = boottime()
+		bootTime.determined = true
+	}
+	return bootTime.Time
+}
+
+// RefreshBoottime determines the system boot time anew, e.g. after the system clock is adjusted.
+func RefreshBoottime() time.Time {
+	bootTime.Lock()
+	defer bootTime.Unlock()
+	bootTime.Time = boottime()
+	bootTime.determined = true
+	return bootTime.Time
+}
+
 // MsToTime converts Unix era milliseconds to Go time.Time.
 func MsToTime(ms uint64) time.Time {
 	var s, n int64
@@ -67,41 +139,200 @@ func MsToTime(ms uint64) time.Time {
 	return time.Unix(s, n)
 }
 
+// WatchOpenFDs samples the count of open file descriptors at each interval until the context
+// is cancelled, logging a warning if the count exceeds the threshold or has grown over each
+// of the last five intervals, either of which suggests a descriptor leak.
+func WatchOpenFDs(ctx context.Context, interval time.Duration, threshold int) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var counts []int
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			n, err := OpenFDCount()
+			if err != nil {
+				Error("WatchOpenFDs", err).Err()
+				return
+			}
+			if counts = append(counts, n); len(counts) > 6 {
+				counts = counts[1:]
+			}
+			growing := len(counts) == 6
+			for i := 1; growing && i < len(counts); i++ {
+				growing = counts[i] > counts[i-1]
+			}
+			if n > threshold || growing {
+				Error("WatchOpenFDs", errors.New("possible file descriptor leak"), map[string]string{
+					"count":     strconv.Itoa(n),
+					"threshold": strconv.Itoa(threshold),
+					"growing":   strconv.FormatBool(growing),
+				}).Warn()
+			}
+		}
+	}()
+}
+
+// MountDiff compares two MountMap samples, reporting the mount points added, removed, and
+// changed (backed by a different file system), each mapped to its file system in curr
+// (or in prev for removed).
+func MountDiff(prev, curr map[string]string) (added, removed, changed map[string]string) {
+	added, removed, changed = map[string]string{}, map[string]string{}, map[string]string{}
+	for mount, fs := range curr {
+		if pfs, ok := prev[mount]; !ok {
+			added[mount] = fs
+		} else if pfs != fs {
+			changed[mount] = fs
+		}
+	}
+	for mount, fs := range prev {
+		if _, ok := curr[mount]; !ok {
+			removed[mount] = fs
+		}
+	}
+	return
+}
+
 // Username retrieves and caches user name for uid.
 func Username(uid int) string {
-	value, _ := unames.lookup(uname(uid))
+	value, _ := unames.Lookup(uname(uid))
 	return value
 }
 
 // Groupname retrieves and caches group name for gid.
 func Groupname(gid int) string {
-	value, _ := gnames.lookup(gname(gid))
+	value, _ := gnames.Lookup(gname(gid))
 	return value
 }
 
-// Hostname retrieves and caches host name for ip address.
+// Uid retrieves and caches the user id for a user name, reporting whether the user exists.
+func Uid(name string) (int, bool) {
+	value, _ := uids.Lookup(uidname(name))
+	return value, value >= 0
+}
+
+// Gid retrieves and caches the group id for a group name, reporting whether the group exists.
+func Gid(name string) (int, bool) {
+	value, _ := gids.Lookup(gidname(name))
+	return value, value >= 0
+}
+
+// PrimeUserCache loads all local users and groups from /etc/passwd and /etc/group into the
+// user and group caches, to avoid a lookup per user and group when enumerating many processes.
+// Only the first call loads the caches.
+func PrimeUserCache() {
+	primeUsers.Do(func() {
+		entries("/etc/passwd", 5, func(fields []string) {
+			if uid, err := strconv.Atoi(fields[2]); err == nil {
+				gecos, _, _ := strings.Cut(fields[4], ",")
+				unames.Store(uname(uid), gecos) // as user.LookupId reports Name
+				uids.Store(uidname(fields[0]), uid)
+			}
+		})
+		entries("/etc/group", 3, func(fields []string) {
+			if gid, err := strconv.Atoi(fields[2]); err == nil {
+				gnames.Store(gname(gid), fields[0])
+				gids.Store(gidname(fields[0]), gid)
+			}
+		})
+	})
+}
+
+// entries reads the colon separated fields of each entry in a user or group database file,
+// skipping entries with fewer than n fields.
+func entries(filename string, n int, fn func([]string)) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return // e.g. on windows
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if fields := strings.Split(line, ":"); len(fields) >= n {
+			fn(fields)
+		}
+	}
+}
+
+// Hostname retrieves and caches host name for ip address. If the address has multiple
+// names, Hostname returns the first.
 func Hostname(addr string) string {
-	value, err := hnames.lookup(hname(addr))
+	return Hostnames(addr)[0]
+}
+
+// Hostnames retrieves and caches the host names for ip address. Until the network lookup
+// of an address completes, or if it fails, the only name is the normalized address. A failed
+// lookup is retried on access after a minute.
+func Hostnames(addr string) []string {
+	value, err := hnames.Lookup(hname(addr))
 
-	if err != nil { // error requests network lookup of hostname
+	// error requests network lookup of hostname, reported only to the caller that cached the
+	// normalized address, so only one lookup of an address is in flight
+	if err != nil {
+		hostLookups.Lock()
+		timeout, retry := hostLookups.timeout, hostLookups.retry
+		hostLookups.Unlock()
 		go func() {
-			if hs, err := net.LookupAddr(value); err == nil { // use normalized name for lookup
-				hnames.Lock()
-				hnames.values[hname(addr)] = hs[0]
-				hnames.Unlock()
+			ctx, cncl := context.WithTimeout(context.Background(), timeout)
+			defer cncl()
+			var r net.Resolver
+			if hs, err := r.LookupAddr(ctx, value[0]); err == nil && len(hs) > 0 { // use normalized name for lookup
+				hnames.Store(hname(addr), hs)
+			} else { // e.g. timed out, forget the address later to look it up again
+				time.AfterFunc(retry, func() { hnames.Invalidate(hname(addr)) })
 			}
 		}()
 	}
 
-	return value
+	return slices.Clone(value)
+}
+
+// SetHostnameLookupTimeout sets how long Hostname waits on the network lookup of a host name
+// before abandoning it, 5 seconds by default.
+func SetHostnameLookupTimeout(d time.Duration) {
+	hostLookups.Lock()
+	hostLookups.timeout = d
+	hostLookups.Unlock()
 }
 
 // Module retrieves and caches go module information.
 func Module(dir string) modval {
-	value, _ := mnames.lookup(moddir(dir))
+	value, _ := mnames.Lookup(moddir(dir))
 	return value
 }
 
+// ResetCaches empties the user, group, host, and module caches, and zeroes their statistics.
+// It is intended primarily for tests and benchmarks that must measure
+// cold versus warm cache behavior without cross-run contamination.
+func ResetCaches() {
+	unames.reset()
+	gnames.reset()
+	uids.reset()
+	gids.reset()
+	hnames.reset()
+	mnames.reset()
+}
+
+// SystemCacheStats reports the statistics of the user and group name, user and group id, host,
+// and module caches.
+func SystemCacheStats() map[string]CacheStats {
+	return map[string]CacheStats{
+		"user":   unames.Stats(),
+		"group":  gnames.Stats(),
+		"uid":    uids.Stats(),
+		"gid":    gids.Stats(),
+		"host":   hnames.Stats(),
+		"module": mnames.Stats(),
+	}
+}
+
 // lookup retrieves a user name.
 func username(uid uname) (string, error) {
 	name := strconv.Itoa(int(uid))
@@ -122,15 +353,41 @@ func groupname(gid gname) (string, error) {
 	return name, nil
 }
 
+// userid retrieves a user id.
+func userid(name uidname) (int, error) {
+	u, err := user.Lookup(string(name))
+	if err != nil {
+		return -1, nil
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil { // e.g. windows SID
+		return -1, nil
+	}
+	return uid, nil
+}
+
+// groupid retrieves a group id.
+func groupid(name gidname) (int, error) {
+	g, err := user.LookupGroup(string(name))
+	if err != nil {
+		return -1, nil
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return -1, nil
+	}
+	return gid, nil
+}
+
 // hostname retrieves a host name.
-func hostname(addr hname) (string, error) {
+func hostname(addr hname) ([]string, error) {
 	name := string(addr)
 	ip, err := netip.ParseAddr(name)
 	if err != nil {
-		return name, nil
+		return []string{name}, nil
 	}
 	name = netip.Addr(ip).String() // normalize name
-	return name, errors.New("lookup hostname")
+	return []string{name}, errors.New("lookup hostname")
 }
 
 // modinfo retrieves go module information.
@@ -0,0 +1,118 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+func TestMountDiff(t *testing.T) {
+	prev := map[string]string{
+		"/":     "/dev/sda1",
+		"/home": "/dev/sda2",
+		"/mnt":  "/dev/sdb1",
+	}
+	tests := []struct {
+		name                    string
+		curr                    map[string]string
+		added, removed, changed map[string]string
+	}{
+		{
+			name:    "unchanged",
+			curr:    maps.Clone(prev),
+			added:   map[string]string{},
+			removed: map[string]string{},
+			changed: map[string]string{},
+		},
+		{
+			name:    "add",
+			curr:    map[string]string{"/": "/dev/sda1", "/home": "/dev/sda2", "/mnt": "/dev/sdb1", "/media": "/dev/sdc1"},
+			added:   map[string]string{"/media": "/dev/sdc1"},
+			removed: map[string]string{},
+			changed: map[string]string{},
+		},
+		{
+			name:    "remove",
+			curr:    map[string]string{"/": "/dev/sda1", "/home": "/dev/sda2"},
+			added:   map[string]string{},
+			removed: map[string]string{"/mnt": "/dev/sdb1"},
+			changed: map[string]string{},
+		},
+		{
+			name:    "device change",
+			curr:    map[string]string{"/": "/dev/sda1", "/home": "/dev/sda2", "/mnt": "/dev/sdc1"},
+			added:   map[string]string{},
+			removed: map[string]string{},
+			changed: map[string]string{"/mnt": "/dev/sdc1"},
+		},
+		{
+			name:    "from empty",
+			curr:    map[string]string{},
+			added:   map[string]string{},
+			removed: maps.Clone(prev),
+			changed: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		added, removed, changed := MountDiff(prev, tt.curr)
+		if !maps.Equal(added, tt.added) || !maps.Equal(removed, tt.removed) || !maps.Equal(changed, tt.changed) {
+			t.Errorf("%s: MountDiff() = %v, %v, %v, want %v, %v, %v",
+				tt.name, added, removed, changed, tt.added, tt.removed, tt.changed)
+		}
+	}
+}
+
+func TestHostnamesRetriesFailedLookup(t *testing.T) {
+	defer func(timeout, retry time.Duration) {
+		hostLookups.timeout, hostLookups.retry = timeout, retry
+	}(hostLookups.timeout, hostLookups.retry)
+	// lookups time out at once, and are retried soon after
+	hostLookups.timeout, hostLookups.retry = time.Nanosecond, 10*time.Millisecond
+	addr := "192.0.2.1" // TEST-NET-1, reserved for documentation
+	hnames.Invalidate(hname(addr))
+
+	if names := Hostnames(addr); len(names) != 1 || names[0] != addr {
+		t.Fatalf("Hostnames(%s) = %v, want the address", addr, names)
+	}
+	misses := hnames.Stats().Misses
+	Hostnames(addr)
+	if m := hnames.Stats().Misses; m != misses {
+		t.Errorf("failed lookup not cached, misses %d, want %d", m, misses)
+	}
+	time.Sleep(100 * time.Millisecond)
+	Hostnames(addr)
+	if m := hnames.Stats().Misses; m != misses+1 {
+		t.Errorf("failed lookup not retried, misses %d, want %d", m, misses+1)
+	}
+}
+
+func TestSystemCacheStats(t *testing.T) {
+	before := SystemCacheStats()
+	Uid("root")
+	Gid("root")
+	after := SystemCacheStats()
+	for _, name := range []string{"user", "group", "uid", "gid", "host", "module"} {
+		if _, ok := after[name]; !ok {
+			t.Errorf("SystemCacheStats() has no %s cache", name)
+		}
+	}
+	for _, name := range []string{"uid", "gid"} {
+		if b, a := before[name], after[name]; a.Hits+a.Misses != b.Hits+b.Misses+1 {
+			t.Errorf("%s cache lookups %d after one lookup, %d before", name, a.Hits+a.Misses, b.Hits+b.Misses)
+		}
+	}
+}
+
+func TestResetCaches(t *testing.T) {
+	Uid("root")
+	Gid("root")
+	Username(0)
+	ResetCaches()
+	for name, stats := range SystemCacheStats() {
+		if stats != (CacheStats{}) {
+			t.Errorf("%s cache stats %+v after ResetCaches, want zero", name, stats)
+		}
+	}
+}
@@ -4,8 +4,15 @@ package gocore
 
 import (
 	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
 	"iter"
+	"maps"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 type (
@@ -15,6 +22,18 @@ type (
 	// Tree defines a hierarchy of Nodes of comparable type.
 	Tree[N Node] map[N]Tree[N]
 
+	// SyncTree guards a Tree for concurrent access. Its zero value is an empty tree.
+	SyncTree[N Node] struct {
+		mu   sync.RWMutex
+		tree Tree[N]
+	}
+
+	// treeJSON is the JSON encoding of a node and its subnodes, preserving the node's type.
+	treeJSON[N Node] struct {
+		Node     N             `json:"node"`
+		Children []treeJSON[N] `json:"children,omitempty"`
+	}
+
 	// Table provides an optional dictionary of values for the Nodes. If used, insert new Nodes here first to ensure uniqueness in Tree.
 	Table[N Node, V any] map[N]V
 )
@@ -112,6 +131,20 @@ func (tr Tree[N]) FindTree(node N) Tree[N] {
 	return nil
 }
 
+// Remove removes a node and its descendants from the tree, reporting whether the node was found.
+func (tr Tree[N]) Remove(node N) bool {
+	if _, ok := tr[node]; ok {
+		delete(tr, node)
+		return true
+	}
+	for _, tr := range tr {
+		if tr.Remove(node) {
+			return true
+		}
+	}
+	return false
+}
+
 func (tr Tree[N]) Family(node N) Tree[N] {
 	if _, ok := tr[node]; ok {
 		return tr
@@ -128,3 +161,194 @@ func (tr Tree[N]) Family(node N) Tree[N] {
 	tb[parent][node] = tr[node]
 	return fam
 }
+
+// DOT renders the tree as a Graphviz digraph, labeling each node with the name function.
+func (tr Tree[N]) DOT(name func(N) string) string {
+	var sb strings.Builder
+	sb.WriteString("digraph {\n")
+	ids := map[N]string{}
+	for _, node := range tr.SortedFunc(cmp.Compare[N]) {
+		ids[node] = "n" + strconv.Itoa(len(ids))
+		fmt.Fprintf(&sb, "  %s [label=%s];\n", ids[node], strconv.Quote(name(node)))
+	}
+	tr.edges(ids, &sb)
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// edges writes an edge from each node to each of its subnodes.
+func (tr Tree[N]) edges(ids map[N]string, sb *strings.Builder) {
+	for _, node := range slices.Sorted(maps.Keys(tr)) {
+		for _, sub := range slices.Sorted(maps.Keys(tr[node])) {
+			fmt.Fprintf(sb, "  %s -> %s;\n", ids[node], ids[sub])
+		}
+		tr[node].edges(ids, sb)
+	}
+}
+
+// MarshalJSON encodes the tree as an ordered list of nodes, each with a list of its children.
+func (tr Tree[N]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(tr.encode())
+}
+
+// encode converts the tree to its JSON encoding.
+func (tr Tree[N]) encode() []treeJSON[N] {
+	nodes := []treeJSON[N]{}
+	for _, node := range slices.Sorted(maps.Keys(tr)) {
+		nodes = append(nodes, treeJSON[N]{
+			Node:     node,
+			Children: tr[node].encode(),
+		})
+	}
+	return nodes
+}
+
+// UnmarshalJSON decodes a tree encoded by MarshalJSON.
+func (tr *Tree[N]) UnmarshalJSON(b []byte) error {
+	var nodes []treeJSON[N]
+	if err := json.Unmarshal(b, &nodes); err != nil {
+		return err
+	}
+	*tr = decode(nodes)
+	return nil
+}
+
+// decode converts the JSON encoding of a tree to the tree.
+func decode[N Node](nodes []treeJSON[N]) Tree[N] {
+	tr := Tree[N]{}
+	for _, node := range nodes {
+		tr[node.Node] = decode(node.Children)
+	}
+	return tr
+}
+
+// Transform maps each node of a tree through a function, producing a tree of the same shape.
+// If the function maps distinct sibling nodes to the same value, their subtrees are merged.
+func Transform[A, B Node](tr Tree[A], f func(A) B) Tree[B] {
+	tb := Tree[B]{}
+	for node, sub := range tr {
+		tb.merge(f(node), Transform(sub, f))
+	}
+	return tb
+}
+
+// merge adds a node with its subtree to the tree, merging it with any existing subtree of the node.
+func (tr Tree[N]) merge(node N, sub Tree[N]) {
+	if _, ok := tr[node]; !ok {
+		tr[node] = sub
+		return
+	}
+	for n, s := range sub {
+		tr[node].merge(n, s)
+	}
+}
+
+// BFS walks the tree breadth first, returning a sequence of each node and its depth in the tree.
+func (tr Tree[N]) BFS() iter.Seq2[int, N] {
+	return func(yield func(int, N) bool) {
+		type level struct {
+			depth int
+			tree  Tree[N]
+		}
+		queue := []level{{0, tr}}
+		for len(queue) > 0 {
+			l := queue[0]
+			queue = queue[1:]
+			for node, sub := range l.tree {
+				if !yield(l.depth, node) {
+					return
+				}
+				if len(sub) > 0 {
+					queue = append(queue, level{l.depth + 1, sub})
+				}
+			}
+		}
+	}
+}
+
+// CommonAncestor finds the deepest node of which both nodes are descendants, or the node
+// itself if one node is an ancestor of the other. It reports false if either node is not in
+// the tree or the nodes are in disjoint trees.
+func (tr Tree[N]) CommonAncestor(a, b N) (N, bool) {
+	var common N
+	ancA, ancB := tr.Ancestors(a), tr.Ancestors(b)
+	if ancA == nil || ancB == nil { // not found
+		return common, false
+	}
+	ancA, ancB = append(ancA, a), append(ancB, b)
+	found := false
+	for i := 0; i < len(ancA) && i < len(ancB) && ancA[i] == ancB[i]; i++ {
+		common, found = ancA[i], true
+	}
+	return common, found
+}
+
+// Descendants returns the nodes of the subtree anchored by a node, excluding the node itself.
+func (tr Tree[N]) Descendants(node N) []N {
+	var nodes []N
+	for _, n := range tr.FindTree(node)[node].All() {
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// Len counts the nodes of the tree.
+func (tr Tree[N]) Len() int {
+	n := len(tr)
+	for _, tr := range tr {
+		n += tr.Len()
+	}
+	return n
+}
+
+// Print writes the tree as an indented outline, like tree(1), ordering the subnodes of
+// each node with a comparison function and labeling each node with the name function.
+func (tr Tree[N]) Print(w io.Writer, name func(N) string, cmp func(a, b N) int) {
+	tr.print(w, "", name, cmp)
+}
+
+// print writes each node's subtree, prefixed by the connectors of its ancestors.
+func (tr Tree[N]) print(w io.Writer, prefix string, name func(N) string, cmp func(a, b N) int) {
+	nodes := slices.SortedFunc(maps.Keys(tr), cmp)
+	for i, node := range nodes {
+		connector, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, indent = "└── ", "    "
+		}
+		fmt.Fprintln(w, prefix+connector+name(node))
+		tr[node].print(w, prefix+indent, name, cmp)
+	}
+}
+
+// Clone returns a deep copy of the tree.
+func (tr Tree[N]) Clone() Tree[N] {
+	clone := make(Tree[N], len(tr))
+	for node, sub := range tr {
+		clone[node] = sub.Clone()
+	}
+	return clone
+}
+
+// Add adds new Nodes as a branch to the tree.
+func (st *SyncTree[N]) Add(nodes ...N) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.tree == nil {
+		st.tree = Tree[N]{}
+	}
+	st.tree.Add(nodes...)
+}
+
+// Remove removes a node and its descendants from the tree, reporting whether the node was found.
+func (st *SyncTree[N]) Remove(node N) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.tree.Remove(node)
+}
+
+// Snapshot returns a copy of the tree that is consistent for iteration while the tree changes.
+func (st *SyncTree[N]) Snapshot() Tree[N] {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return st.tree.Clone()
+}
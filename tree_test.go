@@ -0,0 +1,93 @@
+// Copyright © 2021-2023 The Gomon Project.
+
+package gocore
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// sortedNodes lists the nodes of a tree with their depths in sorted depth first order.
+func sortedNodes[N Node](tr Tree[N]) [][2]any {
+	var nodes [][2]any
+	for depth, node := range tr.SortedFunc(cmp.Compare[N]) {
+		nodes = append(nodes, [2]any{depth, node})
+	}
+	return nodes
+}
+
+func TestTreeJSONRoundTrip(t *testing.T) {
+	tr := Tree[int]{}
+	tr.Add(1, 10, 100)
+	tr.Add(1, 10, 101)
+	tr.Add(1, 11)
+	tr.Add(2, 20, 200, 2000)
+	tr.Add(3)
+
+	b, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Tree[int]
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := sortedNodes(tr), sortedNodes(got); !slices.Equal(got, want) {
+		t.Errorf("round trip of %s\ngot  %v\nwant %v", b, got, want)
+	}
+}
+
+func TestTreeJSONPreservesNodeType(t *testing.T) {
+	tr := Tree[float64]{}
+	tr.Add(1.5, 2.25)
+
+	b, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"node":1.5,"children":[{"node":2.25}]}]`; string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+	var got Tree[float64]
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got[1.5][2.25]; !ok {
+		t.Errorf("Unmarshal() = %v, want node 2.25 under 1.5", got)
+	}
+}
+
+func TestTreeCommonAncestor(t *testing.T) {
+	tr := Tree[string]{}
+	tr.Add("launchd", "login", "zsh", "vim")
+	tr.Add("launchd", "login", "zsh", "make", "cc")
+	tr.Add("launchd", "login", "bash")
+	tr.Add("launchd", "cron")
+	tr.Add("kernel", "kworker")
+
+	tests := []struct {
+		name string
+		a, b string
+		want string
+		ok   bool
+	}{
+		{"siblings", "vim", "make", "zsh", true},
+		{"cousins", "cc", "bash", "login", true},
+		{"distant", "cc", "cron", "launchd", true},
+		{"ancestor and descendant", "login", "cc", "login", true},
+		{"descendant and ancestor", "cc", "zsh", "zsh", true},
+		{"same node", "vim", "vim", "vim", true},
+		{"root and descendant", "launchd", "vim", "launchd", true},
+		{"disjoint subtrees", "vim", "kworker", "", false},
+		{"disjoint roots", "launchd", "kernel", "", false},
+		{"missing node", "vim", "emacs", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := tr.CommonAncestor(tt.a, tt.b); got != tt.want || ok != tt.ok {
+			t.Errorf("%s: CommonAncestor(%s, %s) = %q, %t, want %q, %t",
+				tt.name, tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}